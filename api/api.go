@@ -1,14 +1,19 @@
 package api
 
 import (
+	"crypto/subtle"
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 	"github.com/trustwallet/blockatlas/config"
+	"github.com/trustwallet/blockatlas/db"
 	_ "github.com/trustwallet/blockatlas/docs"
 	"github.com/trustwallet/blockatlas/platform"
 	"github.com/trustwallet/blockatlas/services/tokenindexer"
+	"github.com/trustwallet/blockatlas/services/webhook"
 )
 
 func SetupPlatformAPI(router gin.IRouter) {
@@ -30,6 +35,28 @@ func SetupTokensIndexAPI(router gin.IRouter, instance tokenindexer.Instance) {
 	RegisterTokensIndexAPI(router, instance)
 }
 
+// SetupAdminAPI registers the admin endpoints behind the bearer token, they are disabled without a token.
+// allowed are the non public networks the registered webhooks may target.
+func SetupAdminAPI(router gin.IRouter, database *db.Instance, token string, allowed webhook.Networks) {
+	if token == "" {
+		return
+	}
+	admin := router.Group("/", adminAuth(token))
+	RegisterSubscriptionsAdminAPI(admin, database)
+	RegisterWebhooksAdminAPI(admin, database, allowed)
+}
+
+func adminAuth(token string) gin.HandlerFunc {
+	expected := []byte("Bearer " + token)
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), expected) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
 func SetupSwaggerAPI(router gin.IRouter) {
 	router.GET("swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 }
@@ -0,0 +1,328 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/blockatlas/services/subscriber"
+	"github.com/trustwallet/golibs/types"
+)
+
+const (
+	defaultSubscriptionsLimit = 100
+	maxSubscriptionsLimit     = 1000
+	// maxBulkSubscriptions bounds the addresses of a single add or delete request
+	maxBulkSubscriptions = 1000
+)
+
+var errSubscriptionNotFound = errors.New("subscription not found")
+
+type (
+	// SubscriptionsRequest lists the addresses per coin id, as in subscriber.Event. Extended public keys
+	// are queued for the subscriber, which derives their addresses.
+	SubscriptionsRequest struct {
+		Subscriptions types.Subscriptions       `json:"subscriptions" binding:"required"`
+		Rules         *models.SubscriptionRules `json:"rules,omitempty"`
+		Wallet        string                    `json:"wallet,omitempty"`
+	}
+
+	SubscriptionsResponse struct {
+		Subscriptions int `json:"subscriptions"`
+		QueuedXpubs   int `json:"queued_xpubs"`
+	}
+
+	Subscription struct {
+		ID      uint                     `json:"id"`
+		Coin    uint                     `json:"coin"`
+		Address string                   `json:"address"`
+		Memo    string                   `json:"memo,omitempty"`
+		Rules   models.SubscriptionRules `json:"rules"`
+	}
+
+	SubscriptionsPage struct {
+		Subscriptions []Subscription `json:"subscriptions"`
+		// NextAfterID is the after_id of the next page, zero on the last one
+		NextAfterID uint `json:"next_after_id"`
+	}
+
+	SubscriptionState struct {
+		Subscription
+		Assets  []string `json:"assets"`
+		Wallets []string `json:"wallets"`
+	}
+)
+
+// CreateSubscriptions subscribes the addresses right away, then lets the notifiers and the token indexer know
+func CreateSubscriptions(c *gin.Context, database *db.Instance) {
+	request, subscriptions, xpubs, err := bindSubscriptions(c)
+	if err != nil {
+		return
+	}
+	if err := database.CreateWalletSubscriptions(request.Wallet, subscriptions); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if request.Rules != nil {
+		if err := database.SetSubscriptionRules(addressIDs(subscriptions), *request.Rules); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	if err := publishSubscriptions(types.AddSubscription, subscriptions, xpubs, request.Wallet); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusCreated, SubscriptionsResponse{Subscriptions: len(subscriptions), QueuedXpubs: len(xpubs)})
+}
+
+// DeleteSubscriptions unsubscribes the addresses along with their assets and webhooks, the ones a wallet still
+// references are kept. With a wallet, it is unsubscribed first.
+func DeleteSubscriptions(c *gin.Context, database *db.Instance) {
+	request, subscriptions, xpubs, err := bindSubscriptions(c)
+	if err != nil {
+		return
+	}
+	deleted, err := deleteSubscriptions(database, subscriptions, request.Wallet)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := publishSubscriptions(types.DeleteSubscription, deleted, xpubs, request.Wallet); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, SubscriptionsResponse{Subscriptions: len(deleted), QueuedXpubs: len(xpubs)})
+}
+
+// DeleteSubscription unsubscribes the :coin and :address params, for the wallet query param if set
+func DeleteSubscription(c *gin.Context, database *db.Instance) {
+	subscription, err := getSubscription(c, database)
+	if err != nil {
+		return
+	}
+	view := toSubscription(subscription)
+	subscriptions := []types.Subscription{{Coin: view.Coin, Address: memo.Join(view.Address, view.Memo)}}
+	deleted, err := deleteSubscriptions(database, subscriptions, c.Query("wallet"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := publishSubscriptions(types.DeleteSubscription, deleted, nil, ""); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListSubscriptions pages through the subscriptions by id, optionally of a single coin
+func ListSubscriptions(c *gin.Context, database *db.Instance) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSubscriptionsLimit)))
+	if err != nil || limit <= 0 || limit > maxSubscriptionsLimit {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(errors.New("invalid limit")))
+		return
+	}
+	afterID, err := strconv.ParseUint(c.DefaultQuery("after_id", "0"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(errors.New("invalid after_id")))
+		return
+	}
+	coin := c.Query("coin")
+	if _, err := strconv.ParseUint(coin, 10, 32); coin != "" && err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(errors.New("invalid coin")))
+		return
+	}
+
+	subscriptions, err := database.ListSubscriptions(coin, uint(afterID), limit)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	page := SubscriptionsPage{Subscriptions: make([]Subscription, 0, len(subscriptions))}
+	for _, subscription := range subscriptions {
+		page.Subscriptions = append(page.Subscriptions, toSubscription(subscription))
+	}
+	if len(subscriptions) == limit {
+		page.NextAfterID = subscriptions[len(subscriptions)-1].ID
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// GetSubscription returns the subscription of the :coin and :address params, along with its assets
+func GetSubscription(c *gin.Context, database *db.Instance) {
+	subscription, err := getSubscription(c, database)
+	if err != nil {
+		return
+	}
+	associations, err := database.GetSubscriptionsByAddressIDs([]string{subscription.Address}, time.Time{})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	wallets, err := database.GetSubscriptionWallets([]uint{subscription.ID})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	state := SubscriptionState{
+		Subscription: toSubscription(subscription),
+		Assets:       make([]string, 0, len(associations)),
+		Wallets:      make([]string, 0, len(wallets[subscription.ID])),
+	}
+	for _, association := range associations {
+		// the associations of the other memos of the address are returned too
+		if association.SubscriptionId != subscription.ID {
+			continue
+		}
+		state.Assets = append(state.Assets, association.Asset.Asset)
+	}
+	state.Wallets = append(state.Wallets, wallets[subscription.ID]...)
+	c.JSON(http.StatusOK, state)
+}
+
+// GetSubscriptionRules returns the notification rules of a subscription
+func GetSubscriptionRules(c *gin.Context, database *db.Instance) {
+	subscription, err := getSubscription(c, database)
+	if err != nil {
+		return
+	}
+	c.JSON(http.StatusOK, subscription.Rules)
+}
+
+// SetSubscriptionRules replaces the notification rules of a subscription, an empty object notifies everything
+func SetSubscriptionRules(c *gin.Context, database *db.Instance) {
+	var rules models.SubscriptionRules
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	subscription, err := getSubscription(c, database)
+	if err != nil {
+		return
+	}
+	if err := database.SetSubscriptionRules([]string{subscription.Key()}, rules); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// getSubscription finds the subscription of the :coin and :address params, it aborts the request on error
+func getSubscription(c *gin.Context, database *db.Instance) (models.Subscription, error) {
+	key := canonical.AddressID(c.Param("coin"), c.Param("address"))
+	if coinID, err := strconv.Atoi(c.Param("coin")); err == nil {
+		key = memo.Join(key, memo.Normalize(uint(coinID), c.Query("memo")))
+	}
+	subscriptions, err := database.GetSubscriptionsByKeys([]string{key})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return models.Subscription{}, err
+	}
+	if len(subscriptions) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(errSubscriptionNotFound))
+		return models.Subscription{}, errSubscriptionNotFound
+	}
+	return subscriptions[0], nil
+}
+
+// bindSubscriptions parses the canonical addresses and the xpubs of the request, it aborts the request on error
+func bindSubscriptions(c *gin.Context) (SubscriptionsRequest, []types.Subscription, []types.Subscription, error) {
+	var request SubscriptionsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(err))
+		return request, nil, nil, err
+	}
+	event := types.SubscriptionEvent{Subscriptions: request.Subscriptions}
+	parsed := event.ParseSubscriptions(request.Subscriptions)
+	if len(parsed) > maxBulkSubscriptions {
+		err := fmt.Errorf("at most %d subscriptions per request", maxBulkSubscriptions)
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(err))
+		return request, nil, nil, err
+	}
+	subscriptions, xpubs := subscriber.SplitXpubs(canonical.Subscriptions(parsed))
+	return request, subscriptions, xpubs, nil
+}
+
+// deleteSubscriptions deletes the subscriptions no wallet references, or unsubscribes the wallet from them, and
+// returns the deleted ones
+func deleteSubscriptions(database *db.Instance, subscriptions []types.Subscription, wallet string) ([]types.Subscription, error) {
+	var (
+		deletedIDs []string
+		err        error
+	)
+	if wallet == "" {
+		deletedIDs, err = database.DeleteUnreferencedSubscriptions(addressIDs(subscriptions))
+	} else {
+		deletedIDs, err = database.UnsubscribeWallet(wallet, addressIDs(subscriptions))
+	}
+	if err != nil {
+		return nil, err
+	}
+	deletedSet := make(map[string]bool, len(deletedIDs))
+	for _, addressID := range deletedIDs {
+		deletedSet[addressID] = true
+	}
+	deleted := make([]types.Subscription, 0, len(deletedIDs))
+	for _, subscription := range subscriptions {
+		if deletedSet[subscription.AddressID()] {
+			deleted = append(deleted, subscription)
+		}
+	}
+	return deleted, nil
+}
+
+// publishSubscriptions lets the notifiers and, for added subscriptions, the token indexer know about the
+// addresses, the same way RunSubscriber does. The xpubs are queued for the subscriber.
+func publishSubscriptions(operation types.SubscriptionOperation, subscriptions, xpubs []types.Subscription, wallet string) error {
+	if err := subscriber.QueueXpubs(operation, xpubs, wallet); err != nil {
+		return err
+	}
+	if len(subscriptions) == 0 {
+		return nil
+	}
+	perCoin := make(types.Subscriptions)
+	for _, subscription := range subscriptions {
+		coin := strconv.Itoa(int(subscription.Coin))
+		perCoin[coin] = append(perCoin[coin], subscription.Address)
+	}
+	body, err := json.Marshal(types.SubscriptionEvent{Operation: operation, Subscriptions: perCoin})
+	if err != nil {
+		return err
+	}
+	subscriber.PublishUpdate(body)
+	if operation != types.AddSubscription {
+		return nil
+	}
+	return internal.SubscriptionsTokens.Publish(body)
+}
+
+func addressIDs(subscriptions []types.Subscription) []string {
+	result := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		result = append(result, subscription.AddressID())
+	}
+	return result
+}
+
+func toSubscription(subscription models.Subscription) Subscription {
+	result := Subscription{ID: subscription.ID, Address: subscription.Address, Memo: subscription.Memo, Rules: subscription.Rules}
+	parts := strings.SplitN(subscription.Address, "_", 2)
+	if len(parts) != 2 {
+		return result
+	}
+	if coin, err := strconv.Atoi(parts[0]); err == nil {
+		result.Coin = uint(coin)
+		result.Address = parts[1]
+	}
+	return result
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/memo"
 	"github.com/trustwallet/golibs/types"
 )
 
@@ -76,7 +77,7 @@ func GetTransactionsHistory(c *gin.Context, txAPI blockatlas.TxAPI, tokenTxAPI b
 	}
 
 	filteredTxs := txs.FilterUniqueID().SortByDate()
-	filteredTxs = filteredTxs.FilterTransactionsByMemo()
+	filteredTxs = memo.FilterTransactions(filteredTxs)
 	if token != "" {
 		filteredTxs = filteredTxs.FilterTransactionsByToken(token)
 	}
@@ -143,7 +144,7 @@ func GetTransactionsByXpub(c *gin.Context, api blockatlas.TxUtxoAPI) {
 	}
 
 	filteredTxs := txs.FilterUniqueID().SortByDate()
-	filteredTxs = filteredTxs.FilterTransactionsByMemo()
+	filteredTxs = memo.FilterTransactions(filteredTxs)
 
 	if len(filteredTxs) > types.TxPerPage {
 		filteredTxs = filteredTxs[0:types.TxPerPage]
@@ -0,0 +1,124 @@
+package endpoint
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/blockatlas/services/webhook"
+)
+
+const defaultDeadLettersLimit = 100
+
+type (
+	CreateWebhookRequest struct {
+		Coin    uint   `json:"coin"`
+		Address string `json:"address" binding:"required"`
+		Memo    string `json:"memo"`
+		URL     string `json:"url" binding:"required"`
+		Secret  string `json:"secret" binding:"required"`
+	}
+
+	ReplayWebhooksRequest struct {
+		DeliveryIDs []uint64 `json:"delivery_ids" binding:"required"`
+	}
+
+	ReplayWebhooksResponse struct {
+		Replayed int64 `json:"replayed"`
+	}
+
+	FailedWebhookDelivery struct {
+		DeliveryID uint64          `json:"delivery_id"`
+		WebhookID  uint            `json:"webhook_id"`
+		URL        string          `json:"url"`
+		Attempts   int             `json:"attempts"`
+		LastError  string          `json:"last_error"`
+		FailedAt   int64           `json:"failed_at"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+)
+
+// CreateWebhook registers a webhook on an existing subscription
+func CreateWebhook(c *gin.Context, database *db.Instance, allowed webhook.Networks) {
+	var request CreateWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if err := webhook.ValidateURL(request.URL, allowed); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	addressID := canonical.AddressID(strconv.Itoa(int(request.Coin)), request.Address)
+	key := memo.Join(addressID, memo.Normalize(request.Coin, request.Memo))
+	subscriptions, err := database.GetSubscriptionsByKeys([]string{key})
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if len(subscriptions) == 0 {
+		c.AbortWithStatusJSON(http.StatusNotFound, errorResponse(errors.New("subscription not found")))
+		return
+	}
+
+	hook := models.Webhook{SubscriptionID: subscriptions[0].ID, URL: request.URL, Secret: request.Secret}
+	if err := database.CreateWebhook(&hook); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusCreated, hook)
+}
+
+// GetFailedWebhookDeliveries lists the deliveries parked after all their attempts
+func GetFailedWebhookDeliveries(c *gin.Context, database *db.Instance) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultDeadLettersLimit)))
+	if err != nil || limit <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(errors.New("invalid limit")))
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(errors.New("invalid offset")))
+		return
+	}
+
+	deadLetters, err := database.GetWebhookDeadLetters(limit, offset)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	result := make([]FailedWebhookDelivery, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		result = append(result, FailedWebhookDelivery{
+			DeliveryID: deadLetter.DeliveryID,
+			WebhookID:  deadLetter.WebhookID,
+			URL:        deadLetter.URL,
+			Attempts:   deadLetter.Attempts,
+			LastError:  deadLetter.LastError,
+			FailedAt:   deadLetter.CreatedAt.Unix(),
+			Payload:    deadLetter.Payload,
+		})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ReplayFailedWebhookDeliveries queues the parked deliveries again with fresh attempts
+func ReplayFailedWebhookDeliveries(c *gin.Context, database *db.Instance) {
+	var request ReplayWebhooksRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	replayed, err := database.ReplayWebhookDeadLetters(request.DeliveryIDs)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, ReplayWebhooksResponse{Replayed: replayed})
+}
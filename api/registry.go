@@ -5,9 +5,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/trustwallet/blockatlas/api/endpoint"
+	"github.com/trustwallet/blockatlas/db"
 	"github.com/trustwallet/blockatlas/pkg/blockatlas"
 	"github.com/trustwallet/blockatlas/platform"
 	"github.com/trustwallet/blockatlas/services/tokenindexer"
+	"github.com/trustwallet/blockatlas/services/webhook"
 	"github.com/trustwallet/golibs/network/middleware"
 )
 
@@ -101,6 +103,42 @@ func RegisterBasicAPI(router gin.IRouter) {
 	router.GET("/", endpoint.GetStatus)
 }
 
+func RegisterSubscriptionsAdminAPI(router gin.IRouter, database *db.Instance) {
+	router.GET("/admin/v1/subscriptions", func(c *gin.Context) {
+		endpoint.ListSubscriptions(c, database)
+	})
+	router.POST("/admin/v1/subscriptions", func(c *gin.Context) {
+		endpoint.CreateSubscriptions(c, database)
+	})
+	router.DELETE("/admin/v1/subscriptions", func(c *gin.Context) {
+		endpoint.DeleteSubscriptions(c, database)
+	})
+	router.GET("/admin/v1/subscriptions/:coin/:address", func(c *gin.Context) {
+		endpoint.GetSubscription(c, database)
+	})
+	router.DELETE("/admin/v1/subscriptions/:coin/:address", func(c *gin.Context) {
+		endpoint.DeleteSubscription(c, database)
+	})
+	router.GET("/admin/v1/subscriptions/:coin/:address/rules", func(c *gin.Context) {
+		endpoint.GetSubscriptionRules(c, database)
+	})
+	router.PUT("/admin/v1/subscriptions/:coin/:address/rules", func(c *gin.Context) {
+		endpoint.SetSubscriptionRules(c, database)
+	})
+}
+
+func RegisterWebhooksAdminAPI(router gin.IRouter, database *db.Instance, allowed webhook.Networks) {
+	router.POST("/admin/v1/webhooks", func(c *gin.Context) {
+		endpoint.CreateWebhook(c, database, allowed)
+	})
+	router.GET("/admin/v1/webhooks/failed", func(c *gin.Context) {
+		endpoint.GetFailedWebhookDeliveries(c, database)
+	})
+	router.POST("/admin/v1/webhooks/failed/replay", func(c *gin.Context) {
+		endpoint.ReplayFailedWebhookDeliveries(c, database)
+	})
+}
+
 func RegisterTokensIndexAPI(router gin.IRouter, instance tokenindexer.Instance) {
 	router.GET("/v3/tokens/new", func(c *gin.Context) {
 		endpoint.GetNewTokens(c, instance)
@@ -18,6 +18,7 @@ import (
 	"github.com/trustwallet/blockatlas/internal"
 	"github.com/trustwallet/blockatlas/platform"
 	"github.com/trustwallet/blockatlas/services/tokenindexer"
+	"github.com/trustwallet/blockatlas/services/webhook"
 )
 
 const (
@@ -55,6 +56,11 @@ func init() {
 
 	metrics.Setup(database)
 
+	// The admin API publishes the subscriptions it manages
+	if config.Default.Admin.Token != "" {
+		internal.InitMQ(config.Default.Observer.Rabbitmq.URL)
+	}
+
 	tokenIndexer = tokenindexer.Init(database)
 }
 
@@ -63,6 +69,11 @@ func main() {
 	api.SetupSwaggerAPI(engine)
 	api.SetupPlatformAPI(engine)
 	api.SetupMetrics(engine)
+	allowedNetworks, err := webhook.ParseNetworks(config.Default.Webhooks.AllowedNetworks)
+	if err != nil {
+		log.Fatal("Webhooks allowed networks: ", err)
+	}
+	api.SetupAdminAPI(engine, database, config.Default.Admin.Token, allowedNetworks)
 
 	golibsGin.SetupGracefulShutdown(ctx, port, engine)
 	cancel()
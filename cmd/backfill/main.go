@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/config"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/platform"
+	"github.com/trustwallet/blockatlas/services/backfill"
+	"github.com/trustwallet/blockatlas/services/parser"
+)
+
+const (
+	defaultConfigPath = "../../config.yml"
+)
+
+var (
+	ctx       context.Context
+	cancel    context.CancelFunc
+	database  *db.Instance
+	publisher *internal.Publisher
+
+	coin              = flag.String("coin", "", "coin handle, e.g. ethereum")
+	from              = flag.Int64("from", 0, "first block to backfill")
+	to                = flag.Int64("to", 0, "last block to backfill")
+	name              = flag.String("name", "", "name of the run used to resume, defaults to coin_from_to")
+	resume            = flag.Bool("resume", false, "continue from the saved progress of the run")
+	dryRun            = flag.Bool("dry-run", false, "print normalized transactions instead of publishing them")
+	batchSize         = flag.Int64("batch", 0, "blocks per batch, defaults to observer.block_poll.max_blocks")
+	concurrency       = flag.Int("concurrency", 0, "blocks fetched at once, defaults to the coin fetch options")
+	requestsPerSecond = flag.Float64("rps", 0, "upstream requests per second, defaults to the coin fetch options")
+)
+
+func init() {
+	ctx, cancel = context.WithCancel(context.Background())
+	_, confPath := internal.ParseArgs("", defaultConfigPath)
+
+	internal.InitConfig(confPath)
+
+	if *coin == "" || *from <= 0 || *to < *from {
+		log.Fatal("coin, from and to are required, from must not be greater than to")
+	}
+
+	platform.Init([]string{*coin})
+	if _, ok := platform.BlockAPIs[*coin]; !ok {
+		log.Fatal("No block API for ", *coin)
+	}
+
+	if *dryRun {
+		return
+	}
+
+	internal.InitMQ(config.Default.Observer.Rabbitmq.URL)
+
+	var err error
+	publisher, err = internal.NewPublisher()
+	if err != nil {
+		log.Fatal(err)
+	}
+	database, err = db.New(config.Default.Postgres.URL, config.Default.Postgres.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	options := config.GetFetchOptions(*coin)
+	if *concurrency > 0 {
+		options.Concurrency = *concurrency
+	}
+	if *requestsPerSecond > 0 {
+		options.RequestsPerSecond = *requestsPerSecond
+	}
+	if *batchSize <= 0 {
+		*batchSize = config.Default.Observer.BlockPoll.MaxBlocks
+	}
+	if *name == "" {
+		*name = fmt.Sprintf("%s_%d_%d", *coin, *from, *to)
+	}
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+		cancel()
+	}()
+
+	params := backfill.Params{
+		Name:            *name,
+		Api:             platform.BlockAPIs[*coin],
+		From:            *from,
+		To:              *to,
+		BatchSize:       *batchSize,
+		MaxMessageBytes: config.Default.Observer.Rabbitmq.MaxMessageBytes,
+		Resume:          *resume,
+		DryRun:          *dryRun,
+		Output:          os.Stdout,
+		Scheduler:       parser.NewScheduler(options.Concurrency, options.RequestsPerSecond),
+		Publisher:       publisher,
+		Database:        database,
+	}
+
+	log.WithFields(log.Fields{
+		"name":                *name,
+		"coin":                *coin,
+		"from":                *from,
+		"to":                  *to,
+		"dry run":             *dryRun,
+		"concurrency":         options.Concurrency,
+		"requests per second": options.RequestsPerSecond,
+	}).Info("Start backfill")
+
+	if err := backfill.Run(params, ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Info("Finish backfill")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/trustwallet/golibs/network/middleware"
@@ -13,6 +14,7 @@ import (
 	"github.com/trustwallet/blockatlas/services/tokenindexer"
 
 	"github.com/trustwallet/blockatlas/services/notifier"
+	"github.com/trustwallet/blockatlas/services/webhook"
 
 	"github.com/trustwallet/blockatlas/config"
 	"github.com/trustwallet/blockatlas/services/subscriber"
@@ -20,6 +22,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/trustwallet/blockatlas/db"
 	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/internal/metrics"
 )
 
 const (
@@ -27,14 +30,18 @@ const (
 )
 
 var (
-	ctx      context.Context
-	cancel   context.CancelFunc
-	database *db.Instance
+	ctx           context.Context
+	cancel        context.CancelFunc
+	database      *db.Instance
+	dedupOnce     sync.Once
+	prefilterOnce sync.Once
 
 	transactions        = "transactions"
+	pending             = "pending"
 	tokens              = "tokens"
 	subscriptions       = "subscriptions"
 	subscriptionsTokens = "subscriptions_tokens"
+	webhooks            = "webhooks"
 )
 
 func init() {
@@ -55,6 +62,8 @@ func init() {
 	}
 
 	tokenindexer.Init(database)
+
+	metrics.SetupNotifier(config.Default.Metrics.ConsumerAddress, config.Default.Metrics.Path)
 }
 
 func main() {
@@ -71,14 +80,19 @@ func main() {
 	switch config.Default.Consumer.Service {
 	case transactions:
 		setupTransactionsConsumer(options, ctx)
+	case pending:
+		setupPendingConsumer(options, ctx)
 	case subscriptions:
 		setupSubscriptionsConsumer(subscriptionsOptions, ctx)
 	case subscriptionsTokens:
 		setupSubscriptionsTokensConsumer(options, ctx)
 	case tokens:
 		setupTokensConsumer(options, ctx)
+	case webhooks:
+		setupWebhooksConsumer(options, ctx)
 	default:
 		setupTransactionsConsumer(options, ctx)
+		setupPendingConsumer(options, ctx)
 		setupSubscriptionsConsumer(subscriptionsOptions, ctx)
 		setupSubscriptionsTokensConsumer(options, ctx)
 		setupTokensConsumer(options, ctx)
@@ -92,34 +106,119 @@ func main() {
 }
 
 func setupTransactionsConsumer(options mq.ConsumerOptions, ctx context.Context) {
-	go internal.RawTransactions.RunConsumer(internal.ConsumerDatabase{
+	queue := internal.RawTransactions
+	if config.Default.Consumer.Queue != "" {
+		queue = mq.Queue(config.Default.Consumer.Queue)
+	}
+	log.WithFields(log.Fields{"queue": queue}).Info("Consume transactions")
+
+	setupDeduplicator(ctx)
+	setupPrefilter(ctx)
+	go queue.RunConsumer(retrying(queue, internal.ConsumerDatabase{
 		Database: database,
 		Delivery: notifier.RunNotifier,
 		Tag:      transactions,
-	}, options, ctx)
+	}), options, ctx)
+}
+
+func setupPendingConsumer(options mq.ConsumerOptions, ctx context.Context) {
+	setupDeduplicator(ctx)
+	setupPrefilter(ctx)
+	go internal.RawPendingTransactions.RunConsumer(retrying(internal.RawPendingTransactions, internal.ConsumerDatabase{
+		Database: database,
+		Delivery: notifier.RunNotifier,
+		Tag:      pending,
+	}), options, ctx)
+}
+
+// setupDeduplicator is shared by the transactions and pending services
+func setupDeduplicator(ctx context.Context) {
+	dedupOnce.Do(func() {
+		deduplicator := notifier.NewDeduplicator(database, config.Default.Notifier.Dedup.TTL, config.Default.Notifier.Dedup.MemorySize)
+		notifier.SetupDeduplicator(deduplicator)
+		go deduplicator.RunCleanup(ctx)
+	})
+}
+
+// setupPrefilter is shared by the transactions and pending services, the updates are followed before warming
+// so no subscription added meanwhile is missed
+func setupPrefilter(ctx context.Context) {
+	if !config.Default.Notifier.Prefilter.Enabled {
+		return
+	}
+	prefilterOnce.Do(func() {
+		updates, err := internal.FollowExchange(ctx, internal.SubscriptionsUpdates)
+		if err != nil {
+			log.Fatal("Follow subscriptions updates: ", err)
+		}
+		prefilter := notifier.NewPrefilter(database, config.Default.Notifier.Prefilter.FalsePositiveRate)
+		if err := prefilter.Rebuild(); err != nil {
+			log.Fatal("Warm prefilter: ", err)
+		}
+		notifier.SetupPrefilter(prefilter)
+		go prefilter.Run(ctx, updates, config.Default.Notifier.Prefilter.RebuildInterval)
+	})
 }
 
 func setupSubscriptionsConsumer(options mq.ConsumerOptions, ctx context.Context) {
-	go internal.Subscriptions.RunConsumer(internal.ConsumerDatabase{
+	subscriber.SetupXpubs(platform.XpubAPIs, config.Default.Subscriber.XpubGapLimit)
+	go internal.Subscriptions.RunConsumer(retrying(internal.Subscriptions, internal.ConsumerDatabase{
 		Database: database,
 		Delivery: subscriber.RunSubscriber,
 		Tag:      subscriptions,
-	}, options, ctx)
+	}), options, ctx)
 }
 
 func setupSubscriptionsTokensConsumer(options mq.ConsumerOptions, ctx context.Context) {
-	go internal.SubscriptionsTokens.RunConsumer(tokenindexer.ConsumerIndexer{
+	go internal.SubscriptionsTokens.RunConsumer(retrying(internal.SubscriptionsTokens, tokenindexer.ConsumerIndexer{
 		Database:   database,
 		TokensAPIs: platform.TokensAPIs,
 		Delivery:   tokenindexer.RunTokenIndexerSubscribe,
 		Tag:        subscriptionsTokens,
-	}, options, ctx)
+	}), options, ctx)
 }
 
 func setupTokensConsumer(options mq.ConsumerOptions, ctx context.Context) {
-	go internal.RawTokens.RunConsumer(internal.ConsumerDatabase{
+	go internal.RawTokens.RunConsumer(retrying(internal.RawTokens, internal.ConsumerDatabase{
 		Database: database,
 		Delivery: tokenindexer.RunTokenIndexer,
 		Tag:      tokens,
-	}, options, ctx)
+	}), options, ctx)
+}
+
+// setupWebhooksConsumer takes over the txNotifications queue, it is not part of the default services
+// because it competes with the integrators consuming the queue themselves
+func setupWebhooksConsumer(options mq.ConsumerOptions, ctx context.Context) {
+	allowedNetworks, err := webhook.ParseNetworks(config.Default.Webhooks.AllowedNetworks)
+	if err != nil {
+		log.Fatal("Webhooks allowed networks: ", err)
+	}
+	params := webhook.Params{
+		Database:      database,
+		Client:        webhook.NewClient(config.Default.Webhooks.Timeout, allowedNetworks),
+		MaxAttempts:   config.Default.Webhooks.MaxAttempts,
+		Backoff:       config.Default.Webhooks.Backoff,
+		MaxBackoff:    config.Default.Webhooks.MaxBackoff,
+		RetryInterval: config.Default.Webhooks.RetryInterval,
+	}
+	go internal.TxNotifications.RunConsumer(retrying(internal.TxNotifications, webhook.Consumer{
+		Params: params,
+		Tag:    webhooks,
+	}), options, ctx)
+	go webhook.RunDeliveries(params, ctx)
+}
+
+// retrying retries the transient failures of the consumer through the retry queues declared by setup,
+// and dead letters the rest
+func retrying(queue mq.Queue, consumer mq.Consumer) mq.Consumer {
+	publisher, err := internal.NewPublisher()
+	if err != nil {
+		log.Fatal("MQ publisher: ", err)
+	}
+	return internal.RetryConsumer{
+		Queue:     queue,
+		Consumer:  consumer,
+		Delays:    config.Default.Consumer.RetryDelays,
+		Publisher: publisher,
+	}
 }
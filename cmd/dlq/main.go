@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"github.com/trustwallet/blockatlas/config"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/golibs/network/mq"
+)
+
+// Dlq works on the dead letters of a consumed queue, e.g.
+//   dlq -queue rawTransactions -action list -limit 10
+//   dlq -queue subscriptions -action replay
+//   dlq -queue txNotifications -action purge
+// Replayed messages go back to the queue with fresh retries.
+
+const (
+	defaultConfigPath = "../../config.yml"
+)
+
+var (
+	queue  = flag.String("queue", "", "consumed queue, e.g. rawTransactions")
+	action = flag.String("action", "list", "list, replay or purge")
+	limit  = flag.Int("limit", 100, "dead letters to list or replay")
+)
+
+type deadLetter struct {
+	Headers amqp.Table      `json:"headers"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func init() {
+	_, confPath := internal.ParseArgs("", defaultConfigPath)
+	internal.InitConfig(confPath)
+
+	if *queue == "" {
+		log.Fatal("queue is required")
+	}
+	internal.InitMQ(config.Default.Observer.Rabbitmq.URL)
+}
+
+func main() {
+	defer mq.Close()
+
+	q := mq.Queue(*queue)
+	switch *action {
+	case "list":
+		deadLetters, err := internal.GetDeadLetters(q, *limit)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, msg := range deadLetters {
+			body := json.RawMessage(msg.Body)
+			if !json.Valid(msg.Body) {
+				body, _ = json.Marshal(string(msg.Body))
+			}
+			output, err := json.MarshalIndent(deadLetter{Headers: msg.Headers, Body: body}, "", "  ")
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Println(string(output))
+		}
+		log.WithFields(log.Fields{"queue": internal.DeadLetterQueue(q), "dead_letters": len(deadLetters)}).Info("Listed")
+	case "replay":
+		replayed, err := internal.ReplayDeadLetters(q, *limit)
+		if err != nil {
+			log.WithFields(log.Fields{"queue": q, "replayed": replayed}).Fatal(err)
+		}
+		log.WithFields(log.Fields{"queue": q, "replayed": replayed}).Info("Replayed")
+	case "purge":
+		purged, err := internal.PurgeDeadLetters(q)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.WithFields(log.Fields{"queue": internal.DeadLetterQueue(q), "purged": purged}).Info("Purged")
+	default:
+		log.Fatal("Unknown action ", *action)
+	}
+}
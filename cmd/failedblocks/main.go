@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/config"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/internal"
+)
+
+const (
+	defaultConfigPath = "../../config.yml"
+)
+
+var (
+	database *db.Instance
+
+	coin  = flag.String("coin", "", "coin handle, e.g. ethereum")
+	skip  = flag.Int64("skip", 0, "block number to stop retrying, the parser treats it as done")
+	retry = flag.Int64("retry", 0, "parked block number to hand back to the background retry")
+)
+
+func init() {
+	_, confPath := internal.ParseArgs("", defaultConfigPath)
+
+	internal.InitConfig(confPath)
+
+	var err error
+	database, err = db.New(config.Default.Postgres.URL, config.Default.Postgres.Log)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func main() {
+	if *coin == "" {
+		log.Fatal("coin handle is required")
+	}
+
+	if *skip > 0 {
+		if err := database.SkipFailedBlock(*coin, *skip); err != nil {
+			log.Fatal(err)
+		}
+		log.WithFields(log.Fields{"coin": *coin, "block": *skip}).Info("Skipped failed block")
+		return
+	}
+
+	if *retry > 0 {
+		if err := database.UnparkFailedBlock(*coin, *retry); err != nil {
+			log.Fatal(err)
+		}
+		log.WithFields(log.Fields{"coin": *coin, "block": *retry}).Info("Retrying parked block")
+		return
+	}
+
+	blocks, err := database.GetFailedBlocks(*coin)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NUMBER\tATTEMPTS\tSKIPPED\tPARKED\tUPDATED\tLAST ERROR")
+	for _, block := range blocks {
+		fmt.Fprintf(w, "%d\t%d\t%t\t%t\t%s\t%s\n", block.Number, block.Attempts, block.Skipped, block.Parked, block.UpdatedAt.Format("2006-01-02 15:04:05"), block.LastError)
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/platform"
+)
+
+// Inspect prints what a platform normalizes from its upstream, e.g.
+//   inspect -coin ethereum -method block -block 11000000 -raw
+//   inspect -c ../../configmock.yml -coin tron -method txs -address TM1zzNDZD2DPASbKcgdVoTYhfmYgtfwx9R
+// The second one runs against mock/mockserver, started with make start-mockserver.
+
+const (
+	defaultConfigPath = "../../config.yml"
+)
+
+var (
+	coin    = flag.String("coin", "", "coin handle, e.g. ethereum")
+	method  = flag.String("method", "block", "block, txs, token-txs, validators, delegations, details or balance")
+	block   = flag.Int64("block", 0, "block number, the current block by default")
+	address = flag.String("address", "", "address for txs, token-txs, delegations and balance")
+	token   = flag.String("token", "", "token for token-txs")
+	raw     = flag.Bool("raw", false, "dump the raw upstream responses to stderr")
+)
+
+func init() {
+	_, confPath := internal.ParseArgs("", defaultConfigPath)
+	internal.InitConfig(confPath)
+
+	if *coin == "" {
+		log.Fatal("coin is required")
+	}
+	if *raw {
+		http.DefaultTransport = dumpTransport{next: http.DefaultTransport, out: os.Stderr}
+	}
+	platform.Init([]string{*coin})
+	if _, ok := platform.Platforms[*coin]; !ok {
+		log.Fatal("Unknown coin ", *coin)
+	}
+}
+
+func main() {
+	result, err := inspect(platform.Platforms[*coin])
+	if err != nil {
+		log.Fatal(err)
+	}
+	output, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(string(output))
+}
+
+func inspect(p blockatlas.Platform) (interface{}, error) {
+	switch *method {
+	case "block":
+		api, ok := p.(blockatlas.BlockAPI)
+		if !ok {
+			return nil, unsupported()
+		}
+		num := *block
+		if num == 0 {
+			current, err := api.CurrentBlockNumber()
+			if err != nil {
+				return nil, err
+			}
+			num = current
+		}
+		if headerAPI, ok := api.(blockatlas.BlockHeaderAPI); ok {
+			b, header, err := headerAPI.GetBlockWithHeader(num)
+			return map[string]interface{}{"header": header, "block": b}, err
+		}
+		return api.GetBlockByNumber(num)
+	case "txs":
+		api, ok := p.(blockatlas.TxAPI)
+		if !ok {
+			return nil, unsupported()
+		}
+		return api.GetTxsByAddress(*address)
+	case "token-txs":
+		api, ok := p.(blockatlas.TokenTxAPI)
+		if !ok {
+			return nil, unsupported()
+		}
+		return api.GetTokenTxsByAddress(*address, *token)
+	}
+
+	api, ok := p.(blockatlas.StakeAPI)
+	if !ok {
+		return nil, unsupported()
+	}
+	switch *method {
+	case "validators":
+		return api.GetValidators()
+	case "delegations":
+		return api.GetDelegations(*address)
+	case "details":
+		return api.GetDetails(), nil
+	case "balance":
+		return api.UndelegatedBalance(*address)
+	}
+	return nil, fmt.Errorf("unknown method %s", *method)
+}
+
+func unsupported() error {
+	return fmt.Errorf("%s does not support %s", *coin, *method)
+}
+
+// dumpTransport writes every upstream request and response body
+type dumpTransport struct {
+	next http.RoundTripper
+	out  io.Writer
+}
+
+func (t dumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		fmt.Fprintf(t.out, "> %s %s\n%s\n", req.Method, req.URL, body)
+	} else {
+		fmt.Fprintf(t.out, "> %s %s\n", req.Method, req.URL)
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	fmt.Fprintf(t.out, "< %s\n%s\n\n", res.Status, body)
+	return res, nil
+}
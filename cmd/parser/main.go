@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
@@ -16,13 +15,17 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/trustwallet/blockatlas/db"
 	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
 	"github.com/trustwallet/blockatlas/platform"
+	"github.com/trustwallet/blockatlas/services/mempool"
 	"github.com/trustwallet/blockatlas/services/parser"
 	"github.com/trustwallet/golibs/network/mq"
 )
 
 const (
-	defaultConfigPath = "../../config.yml"
+	defaultConfigPath      = "../../config.yml"
+	defaultShutdownTimeout = time.Second * 30
 )
 
 var (
@@ -57,64 +60,162 @@ func init() {
 
 func main() {
 	defer mq.Close()
-	var (
-		wg          sync.WaitGroup
-		coinCancel  = make(map[string]context.CancelFunc)
-		stopChannel = make(chan<- struct{}, len(platform.BlockAPIs))
-	)
+	supervisor := parser.NewSupervisor(config.Default.Observer.Restart.MinBackoff, config.Default.Observer.Restart.MaxBackoff)
 	minInterval := config.Default.Observer.BlockPoll.Min
 	maxInterval := config.Default.Observer.BlockPoll.Max
-	fetchBlocksTimeout := config.Default.Observer.FetchBlocksInterval
 	maxBlocks := config.Default.Observer.BlockPoll.MaxBlocks
+	reorgDepth := config.Default.Observer.ReorgDepth
+	maxBlockAttempts := config.Default.Observer.FailedBlocks.MaxAttempts
+	retryBlocksInterval := config.Default.Observer.FailedBlocks.RetryInterval
+	leaseTTL := config.Default.Observer.Lease.TTL
+	leaseHolder := getLeaseHolder()
+	outboxInterval := config.Default.Observer.Outbox.Interval
+	outboxRetention := config.Default.Observer.Outbox.Retention
+	priorities := make(map[string]parser.PriorityOptions)
+	for priority, options := range config.Default.Observer.Priorities {
+		priorities[priority] = parser.PriorityOptions{
+			PollFactor:      options.PollFactor,
+			MaxBlocksFactor: options.MaxBlocksFactor,
+		}
+	}
+	var fetchBudget *parser.FetchBudget
+	if config.Default.Observer.FetchBudget > 0 {
+		fetchBudget = parser.NewFetchBudget(config.Default.Observer.FetchBudget)
+	}
+	confirmations := make(map[string][]int64)
+	for handle, thresholds := range config.Default.Observer.Confirmations {
+		confirmations[handle] = parser.ConfirmationThresholds(thresholds)
+	}
+	mempoolCoins := make(map[string]bool)
+	for _, handle := range config.Default.Observer.Mempool.Coins {
+		mempoolCoins[handle] = true
+	}
 
 	go mq.FatalWorker(time.Second * 10)
 
-	wg.Add(len(platform.BlockAPIs))
+	metrics.SetupParser(config.Default.Metrics.ParserAddress, config.Default.Metrics.Path)
+
+	for _, api := range platform.BlockAPIs {
+		if err := parser.ValidateConfirmations(api, confirmations[api.Coin().Handle], reorgDepth); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	for _, api := range platform.BlockAPIs {
 		coin := api.Coin()
 		pollInterval := parser.GetInterval(coin.BlockTime, minInterval, maxInterval)
+		poller := parser.NewPoller(coin.Handle, time.Duration(coin.BlockTime)*time.Millisecond, minInterval, maxInterval)
+		fetchOptions := config.GetFetchOptions(coin.Handle)
+		scheduler := parser.NewScheduler(fetchOptions.Concurrency, fetchOptions.RequestsPerSecond)
+		scheduler.UseBudget(fetchBudget)
+		metrics.RegisterSchedulerQueue(coin.Handle, func() float64 {
+			return float64(scheduler.QueueDepth())
+		})
+
+		var lease *parser.Lease
+		if leaseTTL > 0 {
+			lease = parser.NewLease(database, coin.Handle, leaseHolder, leaseTTL)
+			supervisor.Go(ctx, coin.Handle, "lease", lease.Run)
+		}
 
-		coinCancel[coin.Handle] = cancel
+		publisher, err := internal.NewPublisher()
+		if err != nil {
+			log.Fatal("MQ publisher: ", err)
+		}
 
 		params := parser.Params{
 			Api:                   api,
 			TransactionsExchange:  internal.RawTransactionsExchange,
+			Publisher:             publisher,
 			ParsingBlocksInterval: pollInterval,
-			FetchBlocksTimeout:    fetchBlocksTimeout,
+			Scheduler:             scheduler,
+			Lease:                 lease,
+			OutboxInterval:        outboxInterval,
+			OutboxRetention:       outboxRetention,
 			MaxBlocks:             maxBlocks,
-			StopChannel:           stopChannel,
+			ReorgDepth:            reorgDepth,
+			MaxBlockAttempts:      maxBlockAttempts,
+			RetryBlocksInterval:   retryBlocksInterval,
+			Priorities:            priorities,
+			Poller:                poller,
+			MaxMessageBytes:       config.Default.Observer.Rabbitmq.MaxMessageBytes,
+			Confirmations:         confirmations[coin.Handle],
 			Database:              database,
 		}
 
-		go parser.RunParser(params, ctx)
+		supervisor.Go(ctx, coin.Handle, "parser", func(ctx context.Context) {
+			parser.RunParser(params, ctx)
+		})
+		supervisor.Go(ctx, coin.Handle, "failed blocks", func(ctx context.Context) {
+			parser.RunFailedBlocksRetry(params, ctx)
+		})
+		supervisor.Go(ctx, coin.Handle, "outbox", func(ctx context.Context) {
+			parser.RunOutboxRelay(params, ctx)
+		})
+
+		pendingAPI, ok := api.(blockatlas.PendingTxAPI)
+		if ok && mempoolCoins[coin.Handle] {
+			mempoolParams := mempool.NewParams(mempool.Params{
+				Api:               pendingAPI,
+				Queue:             internal.RawPendingTransactions,
+				BatchSize:         config.Default.Observer.Mempool.BatchSize,
+				FlushInterval:     config.Default.Observer.Mempool.FlushInterval,
+				ReconnectInterval: config.Default.Observer.Mempool.ReconnectInterval,
+				MaxMessageBytes:   config.Default.Observer.Rabbitmq.MaxMessageBytes,
+				Lease:             lease,
+			})
+			supervisor.Go(ctx, coin.Handle, "mempool", func(ctx context.Context) {
+				mempool.RunWatcher(mempoolParams, ctx)
+			})
+			supervisor.Go(ctx, coin.Handle, "mempool collector", func(ctx context.Context) {
+				mempool.RunCollector(mempoolParams, ctx)
+			})
+		}
 
 		log.WithFields(log.Fields{
-			"coin":                 api.Coin().Handle,
-			"interval":             pollInterval,
-			"max blocks":           maxBlocks,
-			"fetch blocks timeout": fetchBlocksTimeout,
+			"coin":                api.Coin().Handle,
+			"interval":            pollInterval,
+			"max blocks":          maxBlocks,
+			"concurrency":         fetchOptions.Concurrency,
+			"requests per second": fetchOptions.RequestsPerSecond,
+			"reorg depth":         reorgDepth,
+			"max block attempts":  maxBlockAttempts,
+			"lease ttl":           leaseTTL,
+			"lease holder":        leaseHolder,
+			"outbox interval":     outboxInterval,
+			"confirmations":       confirmations[coin.Handle],
+			"mempool":             ok && mempoolCoins[coin.Handle],
 		}).Info("Parser params")
-
-		wg.Done()
 	}
 
-	wg.Wait()
-
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	cancel()
 	log.Info("Shutdown parser ...")
-	for coin, cancel := range coinCancel {
-		log.Info(fmt.Sprintf("Starting to stop %s parser...", coin))
-		cancel()
-	}
-	for {
-		if len(stopChannel) == len(platform.BlockAPIs) {
-			log.Info("All parsers are stopped")
-			break
-		}
+	cancel()
+	if !supervisor.Wait(shutdownTimeout()) {
+		log.Error("Parsers did not stop in time")
+		return
 	}
+	log.Info("All parsers are stopped")
 
 	log.Info("Exiting gracefully")
 }
+
+func shutdownTimeout() time.Duration {
+	if timeout := config.Default.Observer.Restart.ShutdownTimeout; timeout > 0 {
+		return timeout
+	}
+	return defaultShutdownTimeout
+}
+
+func getLeaseHolder() string {
+	if holder := config.Default.Observer.Lease.Holder; holder != "" {
+		return holder
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "parser"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
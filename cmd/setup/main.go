@@ -43,10 +43,18 @@ func main() {
 	if err := db.Setup(database.Gorm); err != nil {
 		log.Fatal(err)
 	}
+	rewritten, err := database.CanonicalizeSubscriptions()
+	if err != nil {
+		log.Fatal("Canonicalize subscriptions: ", err)
+	}
+	log.WithFields(log.Fields{"subscriptions": rewritten}).Info("Canonicalized subscriptions")
 
 	if err := internal.RawTransactionsExchange.Declare("topic"); err != nil {
 		log.Fatal(err)
 	}
+	if err := internal.SubscriptionsUpdates.Declare("fanout"); err != nil {
+		log.Fatal(err)
+	}
 
 	queues := []mq.Queue{
 		internal.TxNotifications,
@@ -54,17 +62,41 @@ func main() {
 		internal.Subscriptions,
 		internal.SubscriptionsTokens,
 		internal.RawTokens,
+		internal.RawPendingTransactions,
 		internal.Subscriptions,
 	}
 	for _, queue := range queues {
 		if err := queue.Declare(); err != nil {
 			log.Fatal("Queue declare: ", queue, err)
 		}
+		if err := internal.DeclareDeadLetters(queue, config.Default.Consumer.RetryDelays); err != nil {
+			log.Fatal("Dead letters declare: ", queue, err)
+		}
 	}
 
-	if err := internal.RawTransactionsExchange.Bind([]mq.Queue{internal.RawTokens, internal.RawTransactions}); err != nil {
-		log.Fatal("Transactions Exchange bind: ", err)
+	for _, binding := range getBindings() {
+		queue := mq.Queue(binding.Queue)
+		if err := queue.Declare(); err != nil {
+			log.Fatal("Queue declare: ", queue, err)
+		}
+		if err := internal.DeclareDeadLetters(queue, config.Default.Consumer.RetryDelays); err != nil {
+			log.Fatal("Dead letters declare: ", queue, err)
+		}
+		if err := internal.BindWithRoutingKeys(internal.RawTransactionsExchange, queue, binding.RoutingKeys); err != nil {
+			log.Fatal("Transactions Exchange bind: ", queue, err)
+		}
+		log.WithFields(log.Fields{"queue": queue, "routing_keys": binding.RoutingKeys}).Info("Bound queue")
 	}
 
 	log.Info("Finish setup")
 }
+
+func getBindings() []config.Binding {
+	if len(config.Default.Observer.Rabbitmq.Bindings) > 0 {
+		return config.Default.Observer.Rabbitmq.Bindings
+	}
+	return []config.Binding{
+		{Queue: string(internal.RawTransactions), RoutingKeys: []string{internal.AllTransactionsRoutingKey}},
+		{Queue: string(internal.RawTokens), RoutingKeys: []string{internal.AllTransactionsRoutingKey}},
+	}
+}
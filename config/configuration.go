@@ -16,16 +16,71 @@ type Configuration struct {
 	Platform []string `mapstructure:"platform"`
 	RestAPI  string   `mapstructure:"rest_api"`
 	Observer struct {
-		FetchBlocksInterval time.Duration `mapstructure:"fetch_blocks_interval"`
-		BlockPoll           struct {
+		Fetch         FetchOptions               `mapstructure:"fetch"`
+		Coins         map[string]FetchOptions    `mapstructure:"coins"`
+		FetchBudget   int                        `mapstructure:"fetch_budget"`
+		Priorities    map[string]PriorityOptions `mapstructure:"priorities"`
+		ReorgDepth    int64                      `mapstructure:"reorg_depth"`
+		Confirmations map[string][]int64         `mapstructure:"confirmations"`
+		FailedBlocks  struct {
+			MaxAttempts   int           `mapstructure:"max_attempts"`
+			RetryInterval time.Duration `mapstructure:"retry_interval"`
+		} `mapstructure:"failed_blocks"`
+		Lease struct {
+			TTL    time.Duration `mapstructure:"ttl"`
+			Holder string        `mapstructure:"holder"`
+		} `mapstructure:"lease"`
+		Outbox struct {
+			Interval  time.Duration `mapstructure:"interval"`
+			Retention time.Duration `mapstructure:"retention"`
+		} `mapstructure:"outbox"`
+		Mempool struct {
+			Coins             []string      `mapstructure:"coins"`
+			BatchSize         int           `mapstructure:"batch_size"`
+			FlushInterval     time.Duration `mapstructure:"flush_interval"`
+			ReconnectInterval time.Duration `mapstructure:"reconnect_interval"`
+		} `mapstructure:"mempool"`
+		Restart struct {
+			MinBackoff      time.Duration `mapstructure:"min_backoff"`
+			MaxBackoff      time.Duration `mapstructure:"max_backoff"`
+			ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		} `mapstructure:"restart"`
+		BlockPoll struct {
 			Min       time.Duration `mapstructure:"min"`
 			Max       time.Duration `mapstructure:"max"`
 			MaxBlocks int64         `mapstructure:"max_blocks"`
 		} `mapstructure:"block_poll"`
 		Rabbitmq struct {
-			URL string `mapstructure:"url"`
+			URL             string    `mapstructure:"url"`
+			Bindings        []Binding `mapstructure:"bindings"`
+			MaxMessageBytes int       `mapstructure:"max_message_bytes"`
 		} `mapstructure:"rabbitmq"`
 	} `mapstructure:"observer"`
+	Subscriber struct {
+		XpubGapLimit int `mapstructure:"xpub_gap_limit"`
+	} `mapstructure:"subscriber"`
+	Notifier struct {
+		Dedup struct {
+			TTL        time.Duration `mapstructure:"ttl"`
+			MemorySize int           `mapstructure:"memory_size"`
+		} `mapstructure:"dedup"`
+		Prefilter struct {
+			Enabled           bool          `mapstructure:"enabled"`
+			FalsePositiveRate float64       `mapstructure:"false_positive_rate"`
+			RebuildInterval   time.Duration `mapstructure:"rebuild_interval"`
+		} `mapstructure:"prefilter"`
+	} `mapstructure:"notifier"`
+	Webhooks struct {
+		Timeout         time.Duration `mapstructure:"timeout"`
+		MaxAttempts     int           `mapstructure:"max_attempts"`
+		Backoff         time.Duration `mapstructure:"backoff"`
+		MaxBackoff      time.Duration `mapstructure:"max_backoff"`
+		RetryInterval   time.Duration `mapstructure:"retry_interval"`
+		AllowedNetworks []string      `mapstructure:"allowed_networks"`
+	} `mapstructure:"webhooks"`
+	Admin struct {
+		Token string `mapstructure:"token"`
+	} `mapstructure:"admin"`
 	Postgres struct {
 		URL string `mapstructure:"url"`
 		Log bool   `mapstructure:"log"`
@@ -210,17 +265,55 @@ type Configuration struct {
 		DSN string `mapstructure:"dsn"`
 	} `mapstructure:"sentry"`
 	Metrics struct {
-		Path string `mapstructure:"path"`
+		Path            string `mapstructure:"path"`
+		ParserAddress   string `mapstructure:"parser_address"`
+		ConsumerAddress string `mapstructure:"consumer_address"`
 	} `mapstructure:"metrics"`
 	Consumer struct {
-		Service  string `mapstructure:"service"`
-		Prefetch int    `mapstructure:"prefetch"`
-		Workers  int    `mapstructure:"workers"`
+		Service     string          `mapstructure:"service"`
+		Prefetch    int             `mapstructure:"prefetch"`
+		Workers     int             `mapstructure:"workers"`
+		Queue       string          `mapstructure:"queue"`
+		RetryDelays []time.Duration `mapstructure:"retry_delays"`
 	} `mapstructure:"consumer"`
 }
 
+// FetchOptions bound the block fetching of a coin
+type FetchOptions struct {
+	Concurrency       int     `mapstructure:"concurrency"`
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+}
+
+// PriorityOptions scale the poll interval and the blocks per parse step of the coins with a tracker priority
+type PriorityOptions struct {
+	PollFactor      float64 `mapstructure:"poll_factor"`
+	MaxBlocksFactor float64 `mapstructure:"max_blocks_factor"`
+}
+
+// Binding routes the raw transactions matching the routing keys to a queue
+type Binding struct {
+	Queue       string   `mapstructure:"queue"`
+	RoutingKeys []string `mapstructure:"routing_keys"`
+}
+
 var Default Configuration
 
+// GetFetchOptions returns the fetch options of the coin, falling back to the observer defaults
+func GetFetchOptions(handle string) FetchOptions {
+	options := Default.Observer.Fetch
+	override, ok := Default.Observer.Coins[handle]
+	if !ok {
+		return options
+	}
+	if override.Concurrency > 0 {
+		options.Concurrency = override.Concurrency
+	}
+	if override.RequestsPerSecond > 0 {
+		options.RequestsPerSecond = override.RequestsPerSecond
+	}
+	return options
+}
+
 func Init(confPath string) {
 	c := Configuration{}
 
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"github.com/trustwallet/blockatlas/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetConfirmationStates returns the blocks of the coin up to the number whose transactions didn't reach the last
+// confirmation threshold yet, ordered by number
+func (i *Instance) GetConfirmationStates(coin string, number int64) ([]models.ConfirmationState, error) {
+	var states []models.ConfirmationState
+	if err := i.Gorm.
+		Where("coin = ? AND number <= ?", coin, number).
+		Order("number").
+		Find(&states).Error; err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func saveConfirmationStates(tx *gorm.DB, coin string, pending []models.ConfirmationState, final []int64) error {
+	if len(pending) > 0 {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "coin"}, {Name: "number"}},
+			DoUpdates: clause.AssignmentColumns([]string{"confirmations", "txs", "updated_at"}),
+		}).Create(&pending).Error; err != nil {
+			return err
+		}
+	}
+	if len(final) == 0 {
+		return nil
+	}
+	return tx.
+		Where("coin = ? AND number in ?", coin, final).
+		Delete(&models.ConfirmationState{}).Error
+}
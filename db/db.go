@@ -38,12 +38,34 @@ func New(url string, log bool) (*Instance, error) {
 }
 
 func Setup(db *gorm.DB) error {
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		&models.Tracker{},
+		&models.ParsedBlock{},
+		&models.ConfirmationState{},
+		&models.FailedBlock{},
+		&models.Backfill{},
+		&models.Lease{},
+		&models.OutboxMessage{},
 		&models.Asset{},
 		&models.Subscription{},
 		&models.SubscriptionsAssetAssociation{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.WebhookDeadLetter{},
+		&models.NotificationDedup{},
+		&models.Xpub{},
+		&models.XpubAddress{},
+		&models.Wallet{},
+		&models.WalletSubscription{},
 	)
+	if err != nil {
+		return err
+	}
+	// subscriptions used to be unique by address, they are unique by address and memo now
+	if db.Migrator().HasIndex(&models.Subscription{}, "idx_subscriptions_address") {
+		return db.Migrator().DropIndex(&models.Subscription{}, "idx_subscriptions_address")
+	}
+	return nil
 }
 
 func (i *Instance) MemorySet(key string, data []byte, exp time.Duration) error {
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/trustwallet/blockatlas/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLeaseLost is returned by a write fenced by a lease its holder no longer has
+var ErrLeaseLost = errors.New("lease lost")
+
+// AcquireLease takes the lease of the coin, or extends it if the holder already has it.
+// It returns false while another holder has an unexpired lease. Expiry uses the database clock.
+func (i *Instance) AcquireLease(coin, holder string, ttl time.Duration) (bool, error) {
+	result := i.Gorm.Exec(`
+		INSERT INTO leases (coin, holder, expires_at, updated_at)
+		VALUES (?, ?, now() + ? * interval '1 millisecond', now())
+		ON CONFLICT (coin) DO UPDATE
+		SET holder = excluded.holder, expires_at = excluded.expires_at, updated_at = excluded.updated_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < now()`,
+		coin, holder, ttl.Milliseconds(),
+	)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseLease gives up the lease of the coin if the holder has it, so another replica can take over at once
+func (i *Instance) ReleaseLease(coin, holder string) error {
+	return i.Gorm.
+		Where("coin = ? AND holder = ?", coin, holder).
+		Delete(&models.Lease{}).Error
+}
+
+func (i *Instance) GetLeases() ([]models.Lease, error) {
+	var leases []models.Lease
+	if err := i.Gorm.
+		Where("expires_at > now()").
+		Find(&leases).Error; err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
+
+// checkLease locks the lease of the coin for the rest of the transaction and fails with ErrLeaseLost unless the
+// holder still has it, so a replica whose lease expired can't write after another one took the coin over.
+// An empty holder writes without a lease.
+func checkLease(tx *gorm.DB, coin, holder string) error {
+	if holder == "" {
+		return nil
+	}
+	var leases []models.Lease
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("coin = ? AND holder = ? AND expires_at > now()", coin, holder).
+		Find(&leases).Error; err != nil {
+		return err
+	}
+	if len(leases) == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// NotificationDedup records a published notification, so a redelivered batch doesn't notify it again
+type NotificationDedup struct {
+	Key       string    `gorm:"primaryKey; type:varchar(512)"`
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OutboxMessage is a serialized transactions batch waiting to be published. It is written in the same
+// database transaction as the state change that produced it, so the batch can't be lost in between.
+type OutboxMessage struct {
+	ID           uint64 `gorm:"primary_key"`
+	CreatedAt    time.Time
+	Coin         string     `gorm:"type:varchar(64); index:idx_outbox_messages_pending"`
+	DeliveredAt  *time.Time `gorm:"index:idx_outbox_messages_pending"`
+	RoutingKey   string     `gorm:"type:varchar(128)"`
+	Transactions int
+	Body         []byte
+}
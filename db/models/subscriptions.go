@@ -1,13 +1,35 @@
 package models
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
 	"time"
+
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/golibs/types"
 )
 
 type (
 	Subscription struct {
 		ID      uint   `gorm:"primaryKey;"`
-		Address string `gorm:"uniqueIndex; type:varchar(256); not null;"`
+		Address string `gorm:"uniqueIndex:idx_subscriptions_address_memo; type:varchar(256); not null;"`
+		// Memo scopes the subscription to the transactions with this memo or destination tag, see pkg/memo
+		Memo  string            `gorm:"uniqueIndex:idx_subscriptions_address_memo; type:varchar(128); not null; default:''"`
+		Rules SubscriptionRules `gorm:"type:jsonb"`
+	}
+
+	// SubscriptionRules narrow the transactions notified for a subscription, the zero value notifies all of them
+	SubscriptionRules struct {
+		// MinAmount is the minimum value in the smallest unit of the coin or token
+		MinAmount   string                  `json:"min_amount,omitempty"`
+		Types       []types.TransactionType `json:"types,omitempty"`
+		Direction   types.Direction         `json:"direction,omitempty"`
+		AllowTokens []string                `json:"allow_tokens,omitempty"`
+		DenyTokens  []string                `json:"deny_tokens,omitempty"`
+		RequireMemo bool                    `json:"require_memo,omitempty"`
+		// Memo, if set, must equal the memo of the transaction
+		Memo string `json:"memo,omitempty"`
 	}
 
 	SubscriptionsAssetAssociation struct {
@@ -20,3 +42,27 @@ type (
 		AssetId uint  `gorm:"primary_key; autoIncrement:false; index"`
 	}
 )
+
+// Key is the address id of the subscription followed by its memo, as types.Subscription.AddressID of a memo
+// scoped subscription
+func (s Subscription) Key() string {
+	return memo.Join(s.Address, s.Memo)
+}
+
+func (r SubscriptionRules) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+func (r *SubscriptionRules) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = SubscriptionRules{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("unsupported subscription rules type %T", value)
+	}
+}
@@ -9,3 +9,56 @@ type Tracker struct {
 	Height    int64
 	Enabled   bool `gorm:"default:true" sql:"index"`
 }
+
+// ParsedBlock keeps the hashes and transactions of recently parsed blocks to detect and roll back chain reorganizations
+type ParsedBlock struct {
+	CreatedAt  time.Time
+	Coin       string `gorm:"primary_key:true; type:varchar(64)"`
+	Number     int64  `gorm:"primary_key:true; autoIncrement:false"`
+	Hash       string `gorm:"type:varchar(128)"`
+	ParentHash string `gorm:"type:varchar(128)"`
+	Txs        []byte
+}
+
+// ConfirmationState is a parsed block whose transactions were notified before reaching the last confirmation
+// threshold of the coin, Confirmations is the threshold they were last notified at
+type ConfirmationState struct {
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Coin          string `gorm:"primary_key:true; type:varchar(64)"`
+	Number        int64  `gorm:"primary_key:true; autoIncrement:false"`
+	Confirmations int64
+	Txs           []byte
+}
+
+// FailedBlock is a block height the parser could not fetch, retried in the background once Attempts reaches the limit
+type FailedBlock struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Coin      string `gorm:"primary_key:true; type:varchar(64)"`
+	Number    int64  `gorm:"primary_key:true; autoIncrement:false"`
+	Attempts  int
+	LastError string
+	Skipped   bool `gorm:"default:false"`
+	// Retries counts the failed attempts of the background retry, it parks the height after too many
+	Retries int
+	Parked  bool `gorm:"default:false"`
+}
+
+// Backfill keeps the progress of a backfill run apart from the live tracker
+type Backfill struct {
+	UpdatedAt time.Time
+	Name      string `gorm:"primary_key:true; type:varchar(128)"`
+	Coin      string `gorm:"type:varchar(64)"`
+	From      int64
+	To        int64
+	Height    int64
+}
+
+// Lease grants one parser replica the exclusive right to parse a coin until it expires
+type Lease struct {
+	UpdatedAt time.Time
+	Coin      string `gorm:"primary_key:true; type:varchar(64)"`
+	Holder    string `gorm:"type:varchar(128)"`
+	ExpiresAt time.Time
+}
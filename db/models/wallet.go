@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type (
+	// Wallet is a client watching subscriptions, it is identified by the key the client subscribes with
+	Wallet struct {
+		ID        uint `gorm:"primaryKey"`
+		CreatedAt time.Time
+		Key       string `gorm:"uniqueIndex; type:varchar(256); not null"`
+	}
+
+	// WalletSubscription links a wallet to a subscription, a subscription is kept as long as a wallet links to it
+	WalletSubscription struct {
+		CreatedAt      time.Time
+		Wallet         Wallet       `gorm:"ForeignKey:WalletID; not null"`
+		WalletID       uint         `gorm:"primaryKey; autoIncrement:false"`
+		Subscription   Subscription `gorm:"ForeignKey:SubscriptionID; not null"`
+		SubscriptionID uint         `gorm:"primaryKey; autoIncrement:false; index"`
+	}
+)
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+type (
+	// Webhook receives the notifications of a subscription, signed with its secret
+	Webhook struct {
+		ID             uint `gorm:"primaryKey"`
+		CreatedAt      time.Time
+		SubscriptionID uint   `gorm:"index; not null"`
+		URL            string `gorm:"type:varchar(512); not null"`
+		Secret         string `gorm:"type:varchar(128); not null" json:"-"`
+	}
+
+	// WebhookDelivery is one notification to POST to a webhook, along with the state of its attempts
+	WebhookDelivery struct {
+		ID             uint64 `gorm:"primaryKey"`
+		CreatedAt      time.Time
+		UpdatedAt      time.Time
+		Webhook        Webhook   `gorm:"ForeignKey:WebhookID"`
+		WebhookID      uint      `gorm:"index; not null"`
+		Status         string    `gorm:"type:varchar(16); index:idx_webhook_deliveries_due"`
+		NextAttemptAt  time.Time `gorm:"index:idx_webhook_deliveries_due"`
+		Attempts       int
+		LastStatusCode int
+		LastError      string
+		Payload        []byte
+	}
+
+	// WebhookDeadLetter parks a delivery which failed all its attempts, until it is replayed
+	WebhookDeadLetter struct {
+		CreatedAt  time.Time
+		DeliveryID uint64 `gorm:"primaryKey; autoIncrement:false"`
+		WebhookID  uint   `gorm:"index"`
+		URL        string `gorm:"type:varchar(512)"`
+		Attempts   int
+		LastError  string
+		Payload    []byte
+	}
+)
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type (
+	// Xpub is a subscription to the HD wallet of an extended public key, through the addresses derived from it
+	Xpub struct {
+		ID        uint `gorm:"primaryKey"`
+		CreatedAt time.Time
+		Coin      uint   `gorm:"uniqueIndex:idx_xpubs_coin_key; not null"`
+		Key       string `gorm:"uniqueIndex:idx_xpubs_coin_key; type:varchar(256); not null"`
+	}
+
+	// XpubAddress is an address derived from an Xpub, also stored in subscriptions by its address id
+	XpubAddress struct {
+		Xpub    Xpub   `gorm:"ForeignKey:XpubID"`
+		XpubID  uint   `gorm:"primaryKey; autoIncrement:false"`
+		Address string `gorm:"primaryKey; type:varchar(256); index"`
+		Change  bool
+		Index   int `gorm:"column:derivation_index"`
+		Used    bool
+	}
+)
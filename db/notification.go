@@ -0,0 +1,52 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/trustwallet/blockatlas/db/models"
+)
+
+// ClaimNotifications records the keys and returns those not published within the ttl, the others are duplicates.
+// Expiry uses the database clock.
+func (i *Instance) ClaimNotifications(keys []string, ttl time.Duration) ([]string, error) {
+	claimed := make([]string, 0, len(keys))
+	if len(keys) == 0 {
+		return claimed, nil
+	}
+	values := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys)+1)
+	unique := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if unique[key] {
+			continue
+		}
+		unique[key] = true
+		values = append(values, "(?, now())")
+		args = append(args, key)
+	}
+	args = append(args, ttl.Milliseconds())
+
+	err := i.Gorm.Raw(`
+		INSERT INTO notification_dedups (key, created_at)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (key) DO UPDATE
+		SET created_at = excluded.created_at
+		WHERE notification_dedups.created_at < now() - ? * interval '1 millisecond'
+		RETURNING key`,
+		args...,
+	).Scan(&claimed).Error
+	return claimed, err
+}
+
+// ReleaseNotifications forgets the keys of notifications which could not be published
+func (i *Instance) ReleaseNotifications(keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return i.Gorm.Where("key in (?)", keys).Delete(&models.NotificationDedup{}).Error
+}
+
+func (i *Instance) DeleteExpiredNotifications(before time.Time) error {
+	return i.Gorm.Where("created_at < ?", before).Delete(&models.NotificationDedup{}).Error
+}
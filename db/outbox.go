@@ -0,0 +1,113 @@
+package db
+
+import (
+	"time"
+
+	"github.com/trustwallet/blockatlas/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ParseStep is what a parse step writes at once
+type ParseStep struct {
+	// Height is the last parsed block the tracker moves to
+	Height int64
+	// Blocks are the hashes of the parsed blocks kept for the reorg detection, the ones below KeepFrom are pruned
+	Blocks   []models.ParsedBlock
+	KeepFrom int64
+	Messages []models.OutboxMessage
+	// Pending confirmation states are saved and the Final ones forgotten
+	Pending []models.ConfirmationState
+	Final   []int64
+}
+
+// SaveParseStep moves the tracker, stores the parsed blocks and queues their transactions at once, along with the
+// changes of the confirmation states. Nothing is written unless the holder still has the lease of the coin, see
+// checkLease.
+func (i *Instance) SaveParseStep(coin, holder string, step ParseStep) error {
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := checkLease(tx, coin, holder); err != nil {
+			return err
+		}
+		if err := saveParsedBlocks(tx, coin, step.Blocks, step.KeepFrom); err != nil {
+			return err
+		}
+		if err := setLastParsedBlockNumber(tx, coin, step.Height); err != nil {
+			return err
+		}
+		if err := saveConfirmationStates(tx, coin, step.Pending, step.Final); err != nil {
+			return err
+		}
+		return addOutboxMessages(tx, step.Messages)
+	})
+}
+
+// ResolveFailedBlock forgets the failed height and queues its transactions at once, the pending confirmation
+// states of the block are saved along. Nothing is written unless the holder still has the lease of the coin.
+func (i *Instance) ResolveFailedBlock(coin, holder string, number int64, messages []models.OutboxMessage, pending []models.ConfirmationState) error {
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := checkLease(tx, coin, holder); err != nil {
+			return err
+		}
+		if err := tx.
+			Where("coin = ? AND number = ?", coin, number).
+			Delete(&models.FailedBlock{}).Error; err != nil {
+			return err
+		}
+		if err := saveConfirmationStates(tx, coin, pending, nil); err != nil {
+			return err
+		}
+		return addOutboxMessages(tx, messages)
+	})
+}
+
+// GetOutboxMessages returns the oldest messages of the coin not delivered yet
+func (i *Instance) GetOutboxMessages(coin string, limit int) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	if err := i.Gorm.
+		Where("coin = ? AND delivered_at IS NULL", coin).
+		Order("id").
+		Limit(limit).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (i *Instance) MarkOutboxMessagesDelivered(ids []uint64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return i.Gorm.Model(&models.OutboxMessage{}).
+		Where("id in (?)", ids).
+		Update("delivered_at", time.Now()).Error
+}
+
+// DeleteDeliveredOutboxMessages prunes the messages of the coin delivered before the given time
+func (i *Instance) DeleteDeliveredOutboxMessages(coin string, before time.Time) error {
+	return i.Gorm.
+		Where("coin = ? AND delivered_at < ?", coin, before).
+		Delete(&models.OutboxMessage{}).Error
+}
+
+func addOutboxMessages(tx *gorm.DB, messages []models.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return tx.Create(&messages).Error
+}
+
+func setLastParsedBlockNumber(tx *gorm.DB, coin string, num int64) error {
+	tracker := models.Tracker{
+		Coin:   coin,
+		Height: num,
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{
+				Name: "coin",
+			},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{"height", "updated_at"}),
+	}).Create(&tracker).Error
+}
@@ -1,28 +1,59 @@
 package db
 
 import (
+	"strings"
+
 	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/blockatlas/pkg/memo"
 	"github.com/trustwallet/golibs/types"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+const canonicalizePageSize = 10000
+
 func (i *Instance) CreateSubscriptions(addresses []types.Subscription) error {
+	return createSubscriptions(i.Gorm, addresses)
+}
+
+// CreateWalletSubscriptions creates the subscriptions and links the wallet to them in one transaction, so a
+// wallet unsubscribing at the same time can't delete them before they are linked. No wallet only creates them.
+func (i *Instance) CreateWalletSubscriptions(wallet string, addresses []types.Subscription) error {
+	if wallet == "" {
+		return i.CreateSubscriptions(addresses)
+	}
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := createSubscriptions(tx, addresses); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(addresses))
+		for _, address := range addresses {
+			keys = append(keys, address.AddressID())
+		}
+		return subscribeWallet(tx, wallet, keys)
+	})
+}
+
+func createSubscriptions(tx *gorm.DB, addresses []types.Subscription) error {
 	if len(addresses) == 0 {
 		return nil
 	}
-	// remove duplicates
+	// remove duplicates, of the canonical addresses notifications are matched with
 	addressIds := make(map[string]bool)
-	for _, address := range addresses {
+	for _, address := range canonical.Subscriptions(addresses) {
 		addressIds[address.AddressID()] = true
 	}
 	result := make([]models.Subscription, 0)
 	for addressId := range addressIds {
-		result = append(result, models.Subscription{Address: addressId})
+		address, m := memo.SplitAddressID(addressId)
+		result = append(result, models.Subscription{Address: address, Memo: m})
 	}
 
-	return i.Gorm.Clauses(clause.OnConflict{DoNothing: true}).Create(&result).Error
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&result).Error
 }
 
+// GetSubscriptions returns the subscriptions of the address ids, the memo scoped ones included
 func (i *Instance) GetSubscriptions(addresses []string) ([]models.Subscription, error) {
 	var subscriptions []models.Subscription
 	err := i.Gorm.Find(&subscriptions, "address in ?", addresses).Error
@@ -32,8 +63,22 @@ func (i *Instance) GetSubscriptions(addresses []string) ([]models.Subscription,
 	return subscriptions, nil
 }
 
-func (i *Instance) DeleteSubscriptions(addresses []string) error {
-	subscriptions, err := i.GetSubscriptions(addresses)
+// GetSubscriptionsByKeys returns the subscriptions of the keys, an address id without memo only matches the
+// subscription without memo
+func (i *Instance) GetSubscriptionsByKeys(keys []string) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	if len(keys) == 0 {
+		return subscriptions, nil
+	}
+	if err := whereKeys(i.Gorm, keys).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscriptions deletes the subscriptions of the keys, see GetSubscriptionsByKeys
+func (i *Instance) DeleteSubscriptions(keys []string) error {
+	subscriptions, err := i.GetSubscriptionsByKeys(keys)
 	if err != nil {
 		return err
 	}
@@ -44,12 +89,76 @@ func (i *Instance) DeleteSubscriptions(addresses []string) error {
 	for _, subscription := range subscriptions {
 		subscriptionsIds = append(subscriptionsIds, subscription.ID)
 	}
-	if err = i.Gorm.
+	return deleteSubscriptions(i.Gorm, subscriptionsIds)
+}
+
+// DeleteUnreferencedSubscriptions deletes the subscriptions of the keys no wallet references, and returns the
+// deleted keys. The rows are locked, so a wallet subscribing at the same time keeps its subscription.
+func (i *Instance) DeleteUnreferencedSubscriptions(keys []string) ([]string, error) {
+	deleted := make([]string, 0)
+	if len(keys) == 0 {
+		return deleted, nil
+	}
+	err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+		subscriptions, err := lockSubscriptions(tx, keys)
+		if err != nil || len(subscriptions) == 0 {
+			return err
+		}
+		ids := make([]uint, 0, len(subscriptions))
+		for _, subscription := range subscriptions {
+			ids = append(ids, subscription.ID)
+		}
+		var orphans []models.Subscription
+		if err := tx.
+			Where("id in ?", ids).
+			Where("NOT EXISTS (SELECT 1 FROM wallet_subscriptions WHERE wallet_subscriptions.subscription_id = subscriptions.id)").
+			Find(&orphans).Error; err != nil {
+			return err
+		}
+		if len(orphans) == 0 {
+			return nil
+		}
+		orphanIDs := make([]uint, 0, len(orphans))
+		for _, orphan := range orphans {
+			orphanIDs = append(orphanIDs, orphan.ID)
+			deleted = append(deleted, orphan.Key())
+		}
+		return deleteSubscriptions(tx, orphanIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// lockSubscriptions returns the subscriptions of the keys locked until the end of the transaction, in id order
+// so concurrent transactions lock them in the same order
+func lockSubscriptions(tx *gorm.DB, keys []string) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	if err := whereKeys(tx.Clauses(clause.Locking{Strength: "UPDATE"}), keys).
+		Order("id").
+		Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// deleteSubscriptions removes the subscriptions along with their assets, webhooks and wallet links
+func deleteSubscriptions(tx *gorm.DB, subscriptionsIds []uint) error {
+	if err := tx.
 		Where("subscription_id in (?)", subscriptionsIds).
 		Delete(&models.SubscriptionsAssetAssociation{}).Error; err != nil {
 		return err
 	}
-	return i.Gorm.
+	if err := deleteWebhooks(tx, subscriptionsIds); err != nil {
+		return err
+	}
+	if err := tx.
+		Where("subscription_id in (?)", subscriptionsIds).
+		Delete(&models.WalletSubscription{}).Error; err != nil {
+		return err
+	}
+	return tx.
 		Where("id in (?)", subscriptionsIds).
 		Delete(&models.Subscription{}).Error
 }
@@ -64,3 +173,125 @@ func (i *Instance) CreateSubscriptionsAssets(associations []models.Subscriptions
 			DoUpdates:    clause.AssignmentColumns([]string{"updated_at"})},
 	).Create(&associations).Error
 }
+
+// SetSubscriptionRules replaces the rules of the existing subscriptions of the keys
+func (i *Instance) SetSubscriptionRules(keys []string, rules models.SubscriptionRules) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return whereKeys(i.Gorm.Model(&models.Subscription{}), keys).
+		Update("rules", rules).Error
+}
+
+// whereKeys matches the subscriptions of the keys, the address ids followed by the memo of memo scoped subscriptions
+func whereKeys(tx *gorm.DB, keys []string) *gorm.DB {
+	return tx.Where("(address, memo) IN ?", keyPairs(keys))
+}
+
+func keyPairs(keys []string) [][]interface{} {
+	pairs := make([][]interface{}, 0, len(keys))
+	for _, key := range keys {
+		address, m := memo.SplitAddressID(key)
+		pairs = append(pairs, []interface{}{address, m})
+	}
+	return pairs
+}
+
+// GetSubscriptionsPage returns up to limit subscriptions with an id above afterID, ordered by id
+func (i *Instance) GetSubscriptionsPage(afterID uint, limit int) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	if err := i.Gorm.
+		Select("id", "address", "memo").
+		Where("id > ?", afterID).
+		Order("id").
+		Limit(limit).
+		Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// ListSubscriptions returns up to limit subscriptions of the coin with an id above afterID, ordered by id.
+// An empty coin lists the subscriptions of every coin.
+func (i *Instance) ListSubscriptions(coin string, afterID uint, limit int) ([]models.Subscription, error) {
+	query := i.Gorm.Where("id > ?", afterID)
+	if coin != "" {
+		query = query.Where("address LIKE ?", coin+`\_%`)
+	}
+	var subscriptions []models.Subscription
+	if err := query.Order("id").Limit(limit).Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// CanonicalizeSubscriptions rewrites the addresses of the subscriptions created before they were canonical and
+// returns how many were rewritten. A subscription whose canonical address is already subscribed is merged
+// into it, along with its assets, webhooks and wallets.
+func (i *Instance) CanonicalizeSubscriptions() (int, error) {
+	rewritten := 0
+	var afterID uint
+	for {
+		page, err := i.GetSubscriptionsPage(afterID, canonicalizePageSize)
+		if err != nil {
+			return rewritten, err
+		}
+		for _, subscription := range page {
+			parts := strings.SplitN(subscription.Address, "_", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			address := canonical.AddressID(parts[0], parts[1])
+			if address == subscription.Address {
+				continue
+			}
+			if err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+				return canonicalizeSubscription(tx, subscription, address)
+			}); err != nil {
+				return rewritten, err
+			}
+			rewritten++
+		}
+		if len(page) < canonicalizePageSize {
+			return rewritten, nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+func canonicalizeSubscription(tx *gorm.DB, subscription models.Subscription, address string) error {
+	var existing []models.Subscription
+	if err := tx.Find(&existing, "address = ? AND memo = ?", address, subscription.Memo).Error; err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return tx.Model(&models.Subscription{ID: subscription.ID}).Update("address", address).Error
+	}
+
+	if err := tx.Exec(`INSERT INTO subscriptions_asset_associations (created_at, updated_at, subscription_id, asset_id)
+		SELECT created_at, updated_at, ?, asset_id FROM subscriptions_asset_associations WHERE subscription_id = ?
+		ON CONFLICT DO NOTHING`, existing[0].ID, subscription.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.
+		Where("subscription_id = ?", subscription.ID).
+		Delete(&models.SubscriptionsAssetAssociation{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Webhook{}).
+		Where("subscription_id = ?", subscription.ID).
+		Update("subscription_id", existing[0].ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Exec(`INSERT INTO wallet_subscriptions (created_at, wallet_id, subscription_id)
+		SELECT created_at, wallet_id, ? FROM wallet_subscriptions WHERE subscription_id = ?
+		ON CONFLICT DO NOTHING`, existing[0].ID, subscription.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.
+		Where("subscription_id = ?", subscription.ID).
+		Delete(&models.WalletSubscription{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&models.Subscription{ID: subscription.ID}).Error
+}
@@ -1,7 +1,10 @@
 package db
 
 import (
+	"time"
+
 	"github.com/trustwallet/blockatlas/db/models"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
@@ -24,16 +27,193 @@ func (i *Instance) GetLastParsedBlockNumber(coin string) (models.Tracker, error)
 }
 
 func (i *Instance) SetLastParsedBlockNumber(coin string, num int64) error {
-	tracker := models.Tracker{
-		Coin:   coin,
-		Height: num,
+	return setLastParsedBlockNumber(i.Gorm, coin, num)
+}
+
+func (i *Instance) GetParsedBlocks(coin string, from, to int64) ([]models.ParsedBlock, error) {
+	var blocks []models.ParsedBlock
+	if err := i.Gorm.
+		Where("coin = ? AND number >= ? AND number < ?", coin, from, to).
+		Order("number desc").
+		Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// SaveParsedBlocks stores the blocks and prunes the ones below keepFrom, the parser saves them along with its
+// tracker in SaveParseStep
+func (i *Instance) SaveParsedBlocks(coin string, blocks []models.ParsedBlock, keepFrom int64) error {
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		return saveParsedBlocks(tx, coin, blocks, keepFrom)
+	})
+}
+
+func saveParsedBlocks(tx *gorm.DB, coin string, blocks []models.ParsedBlock, keepFrom int64) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "coin"}, {Name: "number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"hash", "parent_hash", "txs", "created_at"}),
+	}).Create(&blocks).Error; err != nil {
+		return err
+	}
+	return tx.
+		Where("coin = ? AND number < ?", coin, keepFrom).
+		Delete(&models.ParsedBlock{}).Error
+}
+
+// RollbackParsedBlocks rewinds the tracker to the common ancestor, forgets the orphaned blocks along with
+// their confirmation states and queues their reverted transactions. Nothing is written unless the holder still has
+// the lease of the coin.
+func (i *Instance) RollbackParsedBlocks(coin, holder string, ancestor int64, messages []models.OutboxMessage) error {
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := checkLease(tx, coin, holder); err != nil {
+			return err
+		}
+		if err := tx.
+			Where("coin = ? AND number > ?", coin, ancestor).
+			Delete(&models.ParsedBlock{}).Error; err != nil {
+			return err
+		}
+		if err := tx.
+			Where("coin = ? AND number > ?", coin, ancestor).
+			Delete(&models.ConfirmationState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Tracker{}).
+			Where("coin = ?", coin).
+			Updates(map[string]interface{}{"height": ancestor, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return addOutboxMessages(tx, messages)
+	})
+}
+
+// AddFailedBlocks records the failed heights, incrementing the attempts of the known ones, and returns the stored rows
+func (i *Instance) AddFailedBlocks(coin string, failed map[int64]string) ([]models.FailedBlock, error) {
+	if len(failed) == 0 {
+		return nil, nil
+	}
+	blocks := make([]models.FailedBlock, 0, len(failed))
+	numbers := make([]int64, 0, len(failed))
+	for number, lastError := range failed {
+		blocks = append(blocks, models.FailedBlock{Coin: coin, Number: number, Attempts: 1, LastError: lastError})
+		numbers = append(numbers, number)
+	}
+
+	var result []models.FailedBlock
+	err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "coin"}, {Name: "number"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"attempts":   gorm.Expr("failed_blocks.attempts + 1"),
+				"last_error": gorm.Expr("excluded.last_error"),
+				"updated_at": gorm.Expr("excluded.updated_at"),
+			}),
+		}).Create(&blocks).Error; err != nil {
+			return err
+		}
+		return tx.
+			Where("coin = ? AND number in (?)", coin, numbers).
+			Find(&result).Error
+	})
+	return result, err
+}
+
+// GetFailedBlocksToRetry returns the heights handed over to the background retry which were not attempted since before
+func (i *Instance) GetFailedBlocksToRetry(coin string, minAttempts int, before time.Time, limit int) ([]models.FailedBlock, error) {
+	var blocks []models.FailedBlock
+	if err := i.Gorm.
+		Where("coin = ? AND attempts >= ? AND updated_at < ? AND skipped = ? AND parked = ?", coin, minAttempts, before, false, false).
+		Order("number").
+		Limit(limit).
+		Find(&blocks).Error; err != nil {
+		return nil, err
 	}
+	return blocks, nil
+}
+
+func (i *Instance) GetFailedBlocks(coin string) ([]models.FailedBlock, error) {
+	var blocks []models.FailedBlock
+	if err := i.Gorm.
+		Where("coin = ?", coin).
+		Order("number").
+		Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func (i *Instance) DeleteFailedBlocks(coin string, numbers []int64) error {
+	if len(numbers) == 0 {
+		return nil
+	}
+	return i.Gorm.
+		Where("coin = ? AND number in (?)", coin, numbers).
+		Delete(&models.FailedBlock{}).Error
+}
+
+// FailRetriedBlock records a failed attempt of the background retry, and parks the height once it failed
+// maxRetries times, 0 never parks. It reports whether the height got parked.
+func (i *Instance) FailRetriedBlock(coin string, number int64, lastError string, maxRetries int) (bool, error) {
+	var block models.FailedBlock
+	err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.FailedBlock{}).
+			Where("coin = ? AND number = ?", coin, number).
+			Updates(map[string]interface{}{
+				"attempts":   gorm.Expr("attempts + 1"),
+				"retries":    gorm.Expr("retries + 1"),
+				"parked":     gorm.Expr("? > 0 AND retries + 1 >= ?", maxRetries, maxRetries),
+				"last_error": lastError,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.
+			Where("coin = ? AND number = ?", coin, number).
+			First(&block).Error
+	})
+	return block.Parked, err
+}
+
+// UnparkFailedBlock hands a parked height back to the background retry with fresh retries
+func (i *Instance) UnparkFailedBlock(coin string, number int64) error {
+	result := i.Gorm.Model(&models.FailedBlock{}).
+		Where("coin = ? AND number = ? AND parked = ?", coin, number, true).
+		Updates(map[string]interface{}{"parked": false, "retries": 0})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SkipFailedBlock stops retrying the height, the parser treats it as done
+func (i *Instance) SkipFailedBlock(coin string, number int64) error {
+	result := i.Gorm.Model(&models.FailedBlock{}).
+		Where("coin = ? AND number = ?", coin, number).
+		Update("skipped", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (i *Instance) GetBackfill(name string) (models.Backfill, error) {
+	var backfill models.Backfill
+	err := i.Gorm.First(&backfill, "name = ?", name).Error
+	return backfill, err
+}
+
+func (i *Instance) SaveBackfill(backfill models.Backfill) error {
 	return i.Gorm.Clauses(clause.OnConflict{
-		Columns: []clause.Column{
-			{
-				Name: "coin",
-			},
-		},
+		Columns:   []clause.Column{{Name: "name"}},
 		DoUpdates: clause.AssignmentColumns([]string{"height", "updated_at"}),
-	}).Create(&tracker).Error
+	}).Create(&backfill).Error
 }
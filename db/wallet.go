@@ -0,0 +1,166 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/trustwallet/blockatlas/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type walletKey struct {
+	ID  uint
+	Key string
+}
+
+// SubscribeWallet links the wallet to the subscriptions of the keys, the wallet is created on first use
+func (i *Instance) SubscribeWallet(key string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		return subscribeWallet(tx, key, keys)
+	})
+}
+
+// subscribeWallet locks the subscriptions of the keys before linking them, so a wallet unsubscribing at the same
+// time can't delete them in between
+func subscribeWallet(tx *gorm.DB, key string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	subscriptions, err := lockSubscriptions(tx, keys)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+	wallet := models.Wallet{Key: key}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&wallet).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("key = ?", key).First(&wallet).Error; err != nil {
+		return err
+	}
+	links := make([]models.WalletSubscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		links = append(links, models.WalletSubscription{WalletID: wallet.ID, SubscriptionID: subscription.ID})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Omit(clause.Associations).Create(&links).Error
+}
+
+// UnsubscribeWallet unlinks the wallet from the subscriptions of the keys, and deletes the ones no wallet
+// links to anymore. Addresses derived from an xpub are left to DeleteXpub. It returns the deleted keys.
+func (i *Instance) UnsubscribeWallet(key string, keys []string) ([]string, error) {
+	deleted := make([]string, 0)
+	if len(keys) == 0 {
+		return deleted, nil
+	}
+	err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+		// locked, so the last wallet leaving and another one joining are applied one after the other
+		subscriptions, err := lockSubscriptions(tx, keys)
+		if err != nil || len(subscriptions) == 0 {
+			return err
+		}
+		ids := make([]uint, 0, len(subscriptions))
+		for _, subscription := range subscriptions {
+			ids = append(ids, subscription.ID)
+		}
+		if err := tx.
+			Where("subscription_id in ?", ids).
+			Where("wallet_id in (?)", tx.Model(&models.Wallet{}).Select("id").Where("key = ?", key)).
+			Delete(&models.WalletSubscription{}).Error; err != nil {
+			return err
+		}
+
+		var orphans []models.Subscription
+		if err := tx.
+			Where("id in ?", ids).
+			Where("NOT EXISTS (SELECT 1 FROM wallet_subscriptions WHERE wallet_subscriptions.subscription_id = subscriptions.id)").
+			Where("NOT EXISTS (SELECT 1 FROM xpub_addresses WHERE xpub_addresses.address = subscriptions.address)").
+			Find(&orphans).Error; err != nil {
+			return err
+		}
+		if len(orphans) == 0 {
+			return nil
+		}
+		orphanIDs := make([]uint, 0, len(orphans))
+		for _, orphan := range orphans {
+			orphanIDs = append(orphanIDs, orphan.ID)
+			deleted = append(deleted, orphan.Key())
+		}
+		return deleteSubscriptions(tx, orphanIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// UnsubscribeXpubWallet unlinks the wallet from the addresses derived from the xpub, and reports whether
+// another wallet still links to one of them
+func (i *Instance) UnsubscribeXpubWallet(coin uint, key, wallet string) (bool, error) {
+	derived := i.Gorm.Model(&models.Subscription{}).
+		Select("subscriptions.id").
+		Joins("JOIN xpub_addresses ON xpub_addresses.address = subscriptions.address").
+		Joins("JOIN xpubs ON xpubs.id = xpub_addresses.xpub_id").
+		Where("xpubs.coin = ? AND xpubs.key = ?", coin, key)
+	if err := i.Gorm.
+		Where("subscription_id in (?)", derived).
+		Where("wallet_id in (?)", i.Gorm.Model(&models.Wallet{}).Select("id").Where("key = ?", wallet)).
+		Delete(&models.WalletSubscription{}).Error; err != nil {
+		return false, err
+	}
+	var remaining int64
+	if err := i.Gorm.Model(&models.WalletSubscription{}).
+		Where("subscription_id in (?)", derived).
+		Count(&remaining).Error; err != nil {
+		return false, err
+	}
+	return remaining > 0, nil
+}
+
+// GetSubscriptionWallets returns the keys of the wallets linked to each of the subscriptions, sorted
+func (i *Instance) GetSubscriptionWallets(subscriptionIDs []uint) (map[uint][]string, error) {
+	result := make(map[uint][]string)
+	if len(subscriptionIDs) == 0 {
+		return result, nil
+	}
+	var keys []walletKey
+	if err := i.Gorm.Model(&models.WalletSubscription{}).
+		Select("wallet_subscriptions.subscription_id AS id, wallets.key").
+		Joins("JOIN wallets ON wallets.id = wallet_subscriptions.wallet_id").
+		Where("wallet_subscriptions.subscription_id in ?", subscriptionIDs).
+		Scan(&keys).Error; err != nil {
+		return nil, err
+	}
+	return groupWalletKeys(keys), nil
+}
+
+// GetXpubWallets returns the keys of the wallets linked to an address derived from each of the xpubs, sorted
+func (i *Instance) GetXpubWallets(xpubIDs []uint) (map[uint][]string, error) {
+	result := make(map[uint][]string)
+	if len(xpubIDs) == 0 {
+		return result, nil
+	}
+	var keys []walletKey
+	if err := i.Gorm.Model(&models.XpubAddress{}).
+		Distinct("xpub_addresses.xpub_id AS id", "wallets.key").
+		Joins("JOIN subscriptions ON subscriptions.address = xpub_addresses.address").
+		Joins("JOIN wallet_subscriptions ON wallet_subscriptions.subscription_id = subscriptions.id").
+		Joins("JOIN wallets ON wallets.id = wallet_subscriptions.wallet_id").
+		Where("xpub_addresses.xpub_id in ?", xpubIDs).
+		Scan(&keys).Error; err != nil {
+		return nil, err
+	}
+	return groupWalletKeys(keys), nil
+}
+
+func groupWalletKeys(keys []walletKey) map[uint][]string {
+	result := make(map[uint][]string)
+	for _, key := range keys {
+		result[key.ID] = append(result[key.ID], key.Key)
+	}
+	for id := range result {
+		sort.Strings(result[id])
+	}
+	return result
+}
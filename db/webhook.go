@@ -0,0 +1,165 @@
+package db
+
+import (
+	"time"
+
+	"github.com/trustwallet/blockatlas/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func (i *Instance) CreateWebhook(webhook *models.Webhook) error {
+	return i.Gorm.Create(webhook).Error
+}
+
+func (i *Instance) GetWebhooksBySubscriptionIDs(ids []uint) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	if len(ids) == 0 {
+		return webhooks, nil
+	}
+	if err := i.Gorm.Find(&webhooks, "subscription_id in (?)", ids).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// CreateWebhookDeliveries stores the deliveries and sets their ids
+func (i *Instance) CreateWebhookDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return i.Gorm.Omit("Webhook").Create(&deliveries).Error
+}
+
+// ClaimDueWebhookDeliveries returns the pending deliveries whose next attempt is due, with their webhook, and
+// pushes their next attempt lease away so no other sender picks them up while they are attempted. Rows locked by
+// another sender are skipped.
+func (i *Instance) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint64, 0, len(deliveries))
+		webhookIDs := make([]uint, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+			webhookIDs = append(webhookIDs, delivery.WebhookID)
+		}
+		if err := tx.Model(&models.WebhookDelivery{}).
+			Where("id in (?)", ids).
+			Update("next_attempt_at", now.Add(lease)).Error; err != nil {
+			return err
+		}
+
+		var webhooks []models.Webhook
+		if err := tx.Find(&webhooks, "id in (?)", webhookIDs).Error; err != nil {
+			return err
+		}
+		webhooksByID := make(map[uint]models.Webhook, len(webhooks))
+		for _, webhook := range webhooks {
+			webhooksByID[webhook.ID] = webhook
+		}
+		for j := range deliveries {
+			deliveries[j].Webhook = webhooksByID[deliveries[j].WebhookID]
+			deliveries[j].NextAttemptAt = now.Add(lease)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// SaveWebhookDeliveryAttempt records the outcome of an attempt
+func (i *Instance) SaveWebhookDeliveryAttempt(delivery models.WebhookDelivery) error {
+	return i.Gorm.Model(&models.WebhookDelivery{ID: delivery.ID}).Updates(map[string]interface{}{
+		"status":           delivery.Status,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"attempts":         delivery.Attempts,
+		"last_status_code": delivery.LastStatusCode,
+		"last_error":       delivery.LastError,
+	}).Error
+}
+
+// ParkWebhookDelivery marks the delivery failed and moves it to the dead letters at once
+func (i *Instance) ParkWebhookDelivery(delivery models.WebhookDelivery) error {
+	delivery.Status = models.WebhookDeliveryFailed
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.WebhookDelivery{ID: delivery.ID}).Updates(map[string]interface{}{
+			"status":           delivery.Status,
+			"attempts":         delivery.Attempts,
+			"last_status_code": delivery.LastStatusCode,
+			"last_error":       delivery.LastError,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.WebhookDeadLetter{
+			DeliveryID: delivery.ID,
+			WebhookID:  delivery.WebhookID,
+			URL:        delivery.Webhook.URL,
+			Attempts:   delivery.Attempts,
+			LastError:  delivery.LastError,
+			Payload:    delivery.Payload,
+		}).Error
+	})
+}
+
+// GetWebhookDeadLetters returns the parked deliveries, the oldest first
+func (i *Instance) GetWebhookDeadLetters(limit, offset int) ([]models.WebhookDeadLetter, error) {
+	var deadLetters []models.WebhookDeadLetter
+	if err := i.Gorm.
+		Order("created_at").
+		Limit(limit).
+		Offset(offset).
+		Find(&deadLetters).Error; err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+// ReplayWebhookDeadLetters puts the parked deliveries back in the queue with fresh attempts, it returns
+// the number of deliveries replayed
+func (i *Instance) ReplayWebhookDeadLetters(deliveryIDs []uint64) (int64, error) {
+	if len(deliveryIDs) == 0 {
+		return 0, nil
+	}
+	var replayed int64
+	err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("delivery_id in (?)", deliveryIDs).Delete(&models.WebhookDeadLetter{})
+		if result.Error != nil {
+			return result.Error
+		}
+		replayed = result.RowsAffected
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id in (?) AND status = ?", deliveryIDs, models.WebhookDeliveryFailed).
+			Updates(map[string]interface{}{
+				"status":          models.WebhookDeliveryPending,
+				"next_attempt_at": time.Now(),
+				"attempts":        0,
+			}).Error
+	})
+	return replayed, err
+}
+
+// deleteWebhooks removes the webhooks of the subscriptions along with their deliveries
+func deleteWebhooks(tx *gorm.DB, subscriptionIDs []uint) error {
+	webhookIDs := tx.Model(&models.Webhook{}).Select("id").Where("subscription_id in (?)", subscriptionIDs)
+	if err := tx.Where("webhook_id in (?)", webhookIDs).Delete(&models.WebhookDeadLetter{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("webhook_id in (?)", webhookIDs).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("subscription_id in (?)", subscriptionIDs).Delete(&models.Webhook{}).Error
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"github.com/trustwallet/blockatlas/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateXpubAddresses subscribes the addresses derived from the xpub. Addresses already stored keep being
+// used once they were, the derived set only grows.
+func (i *Instance) CreateXpubAddresses(coin uint, key string, addresses []models.XpubAddress) error {
+	return i.Gorm.Transaction(func(tx *gorm.DB) error {
+		xpub := models.Xpub{Coin: coin, Key: key}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&xpub).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("coin = ? AND key = ?", coin, key).First(&xpub).Error; err != nil {
+			return err
+		}
+		if len(addresses) == 0 {
+			return nil
+		}
+
+		subscriptions := make([]models.Subscription, 0, len(addresses))
+		for j := range addresses {
+			addresses[j].XpubID = xpub.ID
+			subscriptions = append(subscriptions, models.Subscription{Address: addresses[j].Address})
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&subscriptions).Error; err != nil {
+			return err
+		}
+		return tx.Omit("Xpub").Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "xpub_id"}, {Name: "address"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"used": gorm.Expr("xpub_addresses.used OR excluded.used"),
+			}),
+		}).Create(&addresses).Error
+	})
+}
+
+// GetXpubAddresses returns the xpub addresses among the address ids, with their xpub
+func (i *Instance) GetXpubAddresses(addresses []string) ([]models.XpubAddress, error) {
+	var xpubAddresses []models.XpubAddress
+	if len(addresses) == 0 {
+		return xpubAddresses, nil
+	}
+	if err := i.Gorm.Preload("Xpub").Find(&xpubAddresses, "address in ?", addresses).Error; err != nil {
+		return nil, err
+	}
+	return xpubAddresses, nil
+}
+
+// MarkXpubAddressesUsed flags the addresses used and returns the xpubs which had one of them unused,
+// their derived set has to be extended
+func (i *Instance) MarkXpubAddressesUsed(addresses []string) ([]models.Xpub, error) {
+	var xpubs []models.Xpub
+	if len(addresses) == 0 {
+		return xpubs, nil
+	}
+	var xpubIDs []uint
+	if err := i.Gorm.
+		Raw("UPDATE xpub_addresses SET used = true WHERE address IN ? AND NOT used RETURNING xpub_id", addresses).
+		Scan(&xpubIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(xpubIDs) == 0 {
+		return xpubs, nil
+	}
+	if err := i.Gorm.Find(&xpubs, "id in ?", xpubIDs).Error; err != nil {
+		return nil, err
+	}
+	return xpubs, nil
+}
+
+// DeleteXpub unsubscribes the xpub along with its derived addresses, unless another xpub derives them too or a
+// wallet subscribed them on its own
+func (i *Instance) DeleteXpub(coin uint, key string) error {
+	var xpubs []models.Xpub
+	if err := i.Gorm.Find(&xpubs, "coin = ? AND key = ?", coin, key).Error; err != nil {
+		return err
+	}
+	if len(xpubs) == 0 {
+		return nil
+	}
+
+	var addresses []string
+	if err := i.Gorm.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.XpubAddress{}).
+			Where("xpub_id = ?", xpubs[0].ID).
+			Pluck("address", &addresses).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("xpub_id = ?", xpubs[0].ID).Delete(&models.XpubAddress{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Xpub{ID: xpubs[0].ID}).Error
+	}); err != nil {
+		return err
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	var shared []string
+	if err := i.Gorm.Model(&models.XpubAddress{}).
+		Where("address in ?", addresses).
+		Pluck("address", &shared).Error; err != nil {
+		return err
+	}
+	sharedSet := make(map[string]bool, len(shared))
+	for _, address := range shared {
+		sharedSet[address] = true
+	}
+	orphans := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if !sharedSet[address] {
+			orphans = append(orphans, address)
+		}
+	}
+	_, err := i.DeleteUnreferencedSubscriptions(orphans)
+	return err
+}
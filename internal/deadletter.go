@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	log "github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"github.com/trustwallet/golibs/network/mq"
+)
+
+// Headers of the retried and dead lettered messages
+const (
+	RetryCountHeader    = "x-retry-count"
+	ErrorHeader         = "x-error"
+	ErrorClassHeader    = "x-error-class"
+	OriginalQueueHeader = "x-original-queue"
+	FailedAtHeader      = "x-failed-at"
+
+	ErrorClassPermanent = "permanent"
+	ErrorClassTransient = "transient"
+)
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks an error retrying won't fix, like a malformed message. It is dead lettered right away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether the error was marked permanent, or is a Postgres data or constraint error
+func IsPermanent(err error) bool {
+	var permanent permanentError
+	if errors.As(err, &permanent) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && len(pgErr.Code) == 5 {
+		class := pgErr.Code[:2]
+		return class == "22" || class == "23"
+	}
+	return false
+}
+
+func DeadLetterQueue(queue mq.Queue) mq.Queue {
+	return queue + ".dlq"
+}
+
+// RetryQueue holds the messages of the queue for the delay, the delay is part of the name since RabbitMQ
+// doesn't let a queue change its ttl
+func RetryQueue(queue mq.Queue, delay time.Duration) mq.Queue {
+	return mq.Queue(fmt.Sprintf("%s.retry.%s", queue, delay))
+}
+
+// DeclareDeadLetters declares the dead letter queue of the queue, and a retry queue per delay which hands
+// its messages back to the queue once they expired
+func DeclareDeadLetters(queue mq.Queue, delays []time.Duration) error {
+	if _, err := publishChannel.QueueDeclare(string(DeadLetterQueue(queue)), true, false, false, false, nil); err != nil {
+		return err
+	}
+	for _, delay := range delays {
+		_, err := publishChannel.QueueDeclare(string(RetryQueue(queue, delay)), true, false, false, false, amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": string(queue),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RetryConsumer classifies the errors of the consumer. Transient errors are retried once per delay,
+// after it, then dead lettered along with the permanent ones. The message is acked in every case, unless it
+// could not be moved.
+type RetryConsumer struct {
+	Queue     mq.Queue
+	Consumer  mq.Consumer
+	Delays    []time.Duration
+	Publisher *Publisher
+}
+
+func (c RetryConsumer) Callback(msg amqp.Delivery) error {
+	err := c.Consumer.Callback(msg)
+	if err == nil {
+		return nil
+	}
+
+	retries := RetryCount(msg)
+	fields := log.Fields{"queue": c.Queue, "retries": retries, "error": err}
+	if !IsPermanent(err) && retries < len(c.Delays) {
+		log.WithFields(fields).Warn("Retry message")
+		headers := copyHeaders(msg.Headers)
+		headers[RetryCountHeader] = int32(retries + 1)
+		return c.Publisher.PublishWithRoutingKey("", string(RetryQueue(c.Queue, c.Delays[retries])), headers, msg.Body)
+	}
+
+	class := ErrorClassTransient
+	if IsPermanent(err) {
+		class = ErrorClassPermanent
+	}
+	fields["class"] = class
+	log.WithFields(fields).Error("Dead letter message")
+	headers := copyHeaders(msg.Headers)
+	headers[ErrorHeader] = err.Error()
+	headers[ErrorClassHeader] = class
+	headers[OriginalQueueHeader] = string(c.Queue)
+	headers[FailedAtHeader] = time.Now().UTC().Format(time.RFC3339)
+	return c.Publisher.PublishWithRoutingKey("", string(DeadLetterQueue(c.Queue)), headers, msg.Body)
+}
+
+// RetryCount returns how many times the message was retried
+func RetryCount(msg amqp.Delivery) int {
+	switch count := msg.Headers[RetryCountHeader].(type) {
+	case int32:
+		return int(count)
+	case int64:
+		return int(count)
+	case int:
+		return count
+	}
+	return 0
+}
+
+// GetDeadLetters returns up to limit dead letters of the queue, they stay in it
+func GetDeadLetters(queue mq.Queue, limit int) ([]amqp.Delivery, error) {
+	channel, err := publishConn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	// Closing the channel puts the unacked messages back in the queue
+	defer channel.Close()
+
+	deadLetters := make([]amqp.Delivery, 0)
+	for len(deadLetters) < limit {
+		msg, ok, err := channel.Get(string(DeadLetterQueue(queue)), false)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		deadLetters = append(deadLetters, msg)
+	}
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters publishes up to limit dead letters of the queue back to it with fresh retries,
+// and returns how many were replayed
+func ReplayDeadLetters(queue mq.Queue, limit int) (int, error) {
+	channel, err := publishConn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer channel.Close()
+	publisher, err := NewPublisher()
+	if err != nil {
+		return 0, err
+	}
+	defer publisher.Close()
+
+	replayed := 0
+	for replayed < limit {
+		msg, ok, err := channel.Get(string(DeadLetterQueue(queue)), false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+		headers := copyHeaders(msg.Headers)
+		for _, header := range []string{RetryCountHeader, ErrorHeader, ErrorClassHeader, OriginalQueueHeader, FailedAtHeader} {
+			delete(headers, header)
+		}
+		if err := publisher.PublishWithRoutingKey("", string(queue), headers, msg.Body); err != nil {
+			return replayed, err
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
+
+// PurgeDeadLetters drops the dead letters of the queue and returns how many there were
+func PurgeDeadLetters(queue mq.Queue) (int, error) {
+	return publishChannel.QueuePurge(string(DeadLetterQueue(queue)), false)
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	result := make(amqp.Table, len(headers)+5)
+	for key, value := range headers {
+		result[key] = value
+	}
+	return result
+}
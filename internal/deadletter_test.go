@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermanent(t *testing.T) {
+	assert.Nil(t, Permanent(nil))
+	assert.False(t, IsPermanent(nil))
+	assert.False(t, IsPermanent(errors.New("connection refused")))
+
+	err := Permanent(errors.New("invalid character"))
+	assert.True(t, IsPermanent(err))
+	assert.True(t, IsPermanent(fmt.Errorf("unmarshal: %w", err)))
+	assert.Equal(t, "invalid character", err.Error())
+
+	assert.True(t, IsPermanent(&pgconn.PgError{Code: "23505"}))
+	assert.True(t, IsPermanent(&pgconn.PgError{Code: "22001"}))
+	assert.False(t, IsPermanent(&pgconn.PgError{Code: "40P01"}))
+}
+
+func TestRetryCount(t *testing.T) {
+	assert.Equal(t, 0, RetryCount(amqp.Delivery{}))
+	assert.Equal(t, 2, RetryCount(amqp.Delivery{Headers: amqp.Table{RetryCountHeader: int32(2)}}))
+	assert.Equal(t, 3, RetryCount(amqp.Delivery{Headers: amqp.Table{RetryCountHeader: int64(3)}}))
+}
+
+func TestDeadLetterQueues(t *testing.T) {
+	assert.Equal(t, "rawTransactions.dlq", string(DeadLetterQueue(RawTransactions)))
+	assert.Equal(t, "subscriptions.retry.30s", string(RetryQueue(Subscriptions, 30*time.Second)))
+	assert.Equal(t, "subscriptions.retry.5m0s", string(RetryQueue(Subscriptions, 5*time.Minute)))
+}
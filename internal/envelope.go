@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/trustwallet/golibs/types"
+)
+
+// EnvelopeVersion is the schema version of the raw transactions envelope
+const EnvelopeVersion = 1
+
+// Confirmation statuses of the transactions of coins notified before their final confirmation
+const (
+	// ConfirmationSeen is a transaction just included in a block
+	ConfirmationSeen = "seen"
+	// ConfirmationConfirmed is a transaction which reached a confirmation threshold, but not the last one
+	ConfirmationConfirmed = "confirmed"
+	// ConfirmationFinal is a transaction which reached the last confirmation threshold, it is not notified again
+	ConfirmationFinal = "final"
+	// ConfirmationReverted is a transaction of a block orphaned by a chain reorganization before it was final
+	ConfirmationReverted = "reverted"
+)
+
+type (
+	// Batch identifies the transactions of one publish, split into one or more envelopes
+	Batch struct {
+		ID        string
+		Coin      uint
+		FromBlock int64
+		ToBlock   int64
+		// Confirmation is set for the coins notified before their final confirmation
+		Confirmation *Confirmation
+	}
+
+	// Confirmation is the depth the transactions of a block were published at
+	Confirmation struct {
+		Status        string `json:"status"`
+		Confirmations int64  `json:"confirmations"`
+	}
+
+	// Envelope wraps a chunk of a batch of raw transactions
+	Envelope struct {
+		Version      int             `json:"version"`
+		Coin         uint            `json:"coin"`
+		FromBlock    int64           `json:"from_block"`
+		ToBlock      int64           `json:"to_block"`
+		BatchID      string          `json:"batch_id"`
+		Chunk        int             `json:"chunk"`
+		Chunks       int             `json:"chunks"`
+		Count        int             `json:"count"`
+		Hash         string          `json:"hash"`
+		Confirmation *Confirmation   `json:"confirmation,omitempty"`
+		Transactions json.RawMessage `json:"transactions"`
+	}
+)
+
+// Envelopes splits the transactions into envelopes which stay under maxBytes once marshalled, 0 means no limit.
+// A transaction larger than the limit gets an envelope of its own.
+func (b Batch) Envelopes(txs types.Txs, maxBytes int) ([]Envelope, error) {
+	limit := maxBytes
+	if maxBytes > 0 {
+		overhead, err := b.overhead(len(txs))
+		if err != nil {
+			return nil, err
+		}
+		limit -= overhead
+	}
+
+	var (
+		chunks [][][]byte
+		chunk  [][]byte
+		size   int
+	)
+	for i := range txs {
+		raw, err := json.Marshal(&txs[i])
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) > 0 && maxBytes > 0 && size+len(raw) > limit {
+			chunks = append(chunks, chunk)
+			chunk, size = nil, 0
+		}
+		chunk = append(chunk, raw)
+		size += len(raw) + 1
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	envelopes := make([]Envelope, 0, len(chunks))
+	for i, chunk := range chunks {
+		transactions := append(append([]byte("["), bytes.Join(chunk, []byte(","))...), ']')
+		envelopes = append(envelopes, Envelope{
+			Version:      EnvelopeVersion,
+			Coin:         b.Coin,
+			FromBlock:    b.FromBlock,
+			ToBlock:      b.ToBlock,
+			BatchID:      b.ID,
+			Chunk:        i,
+			Chunks:       len(chunks),
+			Count:        len(chunk),
+			Hash:         hash(transactions),
+			Confirmation: b.Confirmation,
+			Transactions: transactions,
+		})
+	}
+	return envelopes, nil
+}
+
+// overhead returns the size of an envelope of the batch without its transactions, counted with the widest
+// chunk numbers and count of count transactions
+func (b Batch) overhead(count int) (int, error) {
+	raw, err := json.Marshal(Envelope{
+		Version:      EnvelopeVersion,
+		Coin:         b.Coin,
+		FromBlock:    b.FromBlock,
+		ToBlock:      b.ToBlock,
+		BatchID:      b.ID,
+		Chunk:        count,
+		Chunks:       count,
+		Count:        count,
+		Hash:         hash(nil),
+		Confirmation: b.Confirmation,
+		Transactions: json.RawMessage("[]"),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(raw), nil
+}
+
+// DecodeTransactions reads an envelope, or a plain transactions array published before envelopes, in which case
+// the returned envelope is nil
+func DecodeTransactions(body []byte) (types.Txs, *Envelope, error) {
+	var txs types.Txs
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err := json.Unmarshal(body, &txs)
+		return txs, nil, err
+	}
+
+	var envelope Envelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, nil, err
+	}
+	if err := envelope.validate(); err != nil {
+		return nil, &envelope, err
+	}
+	if err := json.Unmarshal(envelope.Transactions, &txs); err != nil {
+		return nil, &envelope, err
+	}
+	return txs, &envelope, nil
+}
+
+func (e Envelope) validate() error {
+	if e.Version < 1 || e.Version > EnvelopeVersion {
+		return fmt.Errorf("unsupported envelope version %d", e.Version)
+	}
+	if e.Chunks < 1 || e.Chunk < 0 || e.Chunk >= e.Chunks {
+		return fmt.Errorf("invalid chunk %d of %d in batch %s", e.Chunk, e.Chunks, e.BatchID)
+	}
+	if hash(e.Transactions) != e.Hash {
+		return fmt.Errorf("hash mismatch in batch %s chunk %d", e.BatchID, e.Chunk)
+	}
+	return nil
+}
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
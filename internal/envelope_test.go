@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+var envelopeTxs = types.Txs{
+	{ID: "1", Coin: coin.ETHEREUM, Fee: "1", Meta: types.Transfer{Value: "1", Symbol: "ETH", Decimals: 18}},
+	{ID: "2", Coin: coin.ETHEREUM, Fee: "1", Meta: types.Transfer{Value: "2", Symbol: "ETH", Decimals: 18}},
+	{ID: "3", Coin: coin.ETHEREUM, Fee: "1", Meta: types.Transfer{Value: "3", Symbol: "ETH", Decimals: 18}},
+}
+
+func TestBatch_Envelopes(t *testing.T) {
+	batch := Batch{ID: "parsed:ethereum:10-12", Coin: coin.ETHEREUM, FromBlock: 10, ToBlock: 12}
+
+	envelopes, err := batch.Envelopes(envelopeTxs, 0)
+	assert.Nil(t, err)
+	assert.Len(t, envelopes, 1)
+	assert.Equal(t, 3, envelopes[0].Count)
+	assert.Equal(t, EnvelopeVersion, envelopes[0].Version)
+
+	raw, err := json.Marshal(&envelopeTxs[0])
+	assert.Nil(t, err)
+	overhead, err := batch.overhead(len(envelopeTxs))
+	assert.Nil(t, err)
+	maxBytes := overhead + len(raw)*2 + 1
+	envelopes, err = batch.Envelopes(envelopeTxs, maxBytes)
+	assert.Nil(t, err)
+	assert.Len(t, envelopes, 2)
+	assert.Equal(t, 2, envelopes[0].Count)
+	assert.Equal(t, 1, envelopes[1].Count)
+
+	envelopes, err = batch.Envelopes(envelopeTxs, maxBytes-1)
+	assert.Nil(t, err)
+	assert.Len(t, envelopes, 3)
+
+	envelopes, err = batch.Envelopes(envelopeTxs, maxBytes)
+	assert.Nil(t, err)
+	var ids []string
+	for i, envelope := range envelopes {
+		body, err := json.Marshal(envelope)
+		assert.Nil(t, err)
+		assert.LessOrEqual(t, len(body), maxBytes)
+		txs, decoded, err := DecodeTransactions(body)
+		assert.Nil(t, err)
+		assert.Equal(t, i, decoded.Chunk)
+		assert.Equal(t, 2, decoded.Chunks)
+		assert.Equal(t, "parsed:ethereum:10-12", decoded.BatchID)
+		assert.Equal(t, int64(10), decoded.FromBlock)
+		for _, tx := range txs {
+			ids = append(ids, tx.ID)
+		}
+	}
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+
+	envelopes, err = batch.Envelopes(nil, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, envelopes)
+}
+
+func TestDecodeTransactions_Legacy(t *testing.T) {
+	body, err := json.Marshal(envelopeTxs)
+	assert.Nil(t, err)
+
+	txs, envelope, err := DecodeTransactions(body)
+	assert.Nil(t, err)
+	assert.Nil(t, envelope)
+	assert.Len(t, txs, 3)
+}
+
+func TestDecodeTransactions_Invalid(t *testing.T) {
+	envelopes, err := Batch{ID: "b", Coin: coin.ETHEREUM}.Envelopes(envelopeTxs, 0)
+	assert.Nil(t, err)
+
+	tampered := envelopes[0]
+	tampered.Hash = "00"
+	body, err := json.Marshal(tampered)
+	assert.Nil(t, err)
+	_, _, err = DecodeTransactions(body)
+	assert.NotNil(t, err)
+
+	unsupported := envelopes[0]
+	unsupported.Version = EnvelopeVersion + 1
+	body, err = json.Marshal(unsupported)
+	assert.Nil(t, err)
+	_, _, err = DecodeTransactions(body)
+	assert.NotNil(t, err)
+
+	outOfRange := envelopes[0]
+	outOfRange.Chunk = 1
+	body, err = json.Marshal(outOfRange)
+	assert.Nil(t, err)
+	_, _, err = DecodeTransactions(body)
+	assert.NotNil(t, err)
+}
+
+func TestBatch_Envelopes_Confirmation(t *testing.T) {
+	batch := Batch{
+		ID:           "confirmed:bitcoin:10-10",
+		Coin:         coin.BITCOIN,
+		FromBlock:    10,
+		ToBlock:      10,
+		Confirmation: &Confirmation{Status: ConfirmationConfirmed, Confirmations: 3},
+	}
+	envelopes, err := batch.Envelopes(envelopeTxs[:1], 0)
+	assert.Nil(t, err)
+	body, err := json.Marshal(envelopes[0])
+	assert.Nil(t, err)
+	_, decoded, err := DecodeTransactions(body)
+	assert.Nil(t, err)
+	assert.Equal(t, &Confirmation{Status: ConfirmationConfirmed, Confirmations: 3}, decoded.Confirmation)
+
+	batch.Confirmation = nil
+	envelopes, err = batch.Envelopes(envelopeTxs[:1], 0)
+	assert.Nil(t, err)
+	body, err = json.Marshal(envelopes[0])
+	assert.Nil(t, err)
+	assert.NotContains(t, string(body), "confirmation")
+}
@@ -57,4 +57,8 @@ func InitMQ(url string) {
 	if err != nil {
 		log.Fatal("Failed to init Rabbit MQ", err)
 	}
+	err = initPublisher(url)
+	if err != nil {
+		log.Fatal("Failed to init Rabbit MQ publisher", err)
+	}
 }
@@ -25,6 +25,19 @@ var (
 			"enabled",
 		},
 	)
+
+	workerLease = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "worker",
+			Name:      "lease",
+			Help:      "Parser replica holding the coin lease",
+		},
+		[]string{
+			"coin",
+			"holder",
+		},
+	)
 )
 
 func setupUpdateTrackerMetrics(db *db.Instance) {
@@ -38,6 +51,12 @@ func setupUpdateTrackerMetrics(db *db.Instance) {
 				labels := prometheus.Labels{"coin": tracker.Coin, "priority": tracker.Priority, "enabled": strconv.FormatBool(tracker.Enabled)}
 				workerBlockParsing.With(labels).Set(float64(tracker.Height))
 			}
+			if leases, err := db.GetLeases(); err == nil {
+				workerLease.Reset()
+				for _, lease := range leases {
+					workerLease.With(prometheus.Labels{"coin": lease.Coin, "holder": lease.Holder}).Set(1)
+				}
+			}
 			time.Sleep(1 * time.Second)
 		}
 	}()
@@ -48,6 +67,7 @@ func Setup(db *db.Instance) {
 	prometheus.DefaultRegisterer.Unregister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
 
 	prometheus.MustRegister(workerBlockParsing)
+	prometheus.MustRegister(workerLease)
 
 	setupUpdateTrackerMetrics(db)
 }
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LayerMemory   = "memory"
+	LayerDatabase = "database"
+)
+
+var (
+	notifierDuplicates = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "notifier",
+			Name:      "duplicates_suppressed_total",
+			Help:      "Notifications not published again, by the dedup layer which knew them",
+		},
+		[]string{"coin", "layer"},
+	)
+
+	notifierPublished = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "notifier",
+			Name:      "published_notifications_total",
+			Help:      "Notifications published to txNotifications",
+		},
+		[]string{"coin"},
+	)
+
+	prefilterBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "notifier",
+			Name:      "prefilter_bytes",
+			Help:      "Memory used by the bloom filter of subscribed addresses",
+		},
+	)
+
+	prefilterFalsePositiveRate = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "notifier",
+			Name:      "prefilter_false_positive_rate",
+			Help:      "Estimated false positive rate of the bloom filter of subscribed addresses",
+		},
+	)
+
+	prefilterLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "notifier",
+			Name:      "prefilter_lookups_total",
+			Help:      "Addresses passed by the prefilter and queried, by whether they were subscribed",
+		},
+		[]string{"coin", "result"},
+	)
+)
+
+// SetupNotifier registers the notifier metrics and serves them on the address, if any
+func SetupNotifier(address, path string) {
+	prometheus.MustRegister(
+		notifierDuplicates,
+		notifierPublished,
+		prefilterBytes,
+		prefilterFalsePositiveRate,
+		prefilterLookups,
+	)
+	if address == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/"+path, promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.WithFields(log.Fields{"address": address}).Fatal(err)
+		}
+	}()
+}
+
+func AddDuplicates(coin, layer string, count int) {
+	if count > 0 {
+		notifierDuplicates.WithLabelValues(coin, layer).Add(float64(count))
+	}
+}
+
+func AddNotifications(coin string, count int) {
+	notifierPublished.WithLabelValues(coin).Add(float64(count))
+}
+
+func ObservePrefilter(bytes int, falsePositiveRate float64) {
+	prefilterBytes.Set(float64(bytes))
+	prefilterFalsePositiveRate.Set(falsePositiveRate)
+}
+
+// AddPrefilterLookups counts the addresses queried after the prefilter, hits are the subscribed ones
+func AddPrefilterLookups(coin string, queried, hits int) {
+	prefilterLookups.WithLabelValues(coin, "hit").Add(float64(hits))
+	prefilterLookups.WithLabelValues(coin, "false_positive").Add(float64(queried - hits))
+}
@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	SourceBlocks  = "blocks"
+	SourceMempool = "mempool"
+
+	ResultRecovered = "recovered"
+	ResultFailed    = "failed"
+	ResultParked    = "parked"
+)
+
+var (
+	parserChainHead = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "chain_head",
+			Help:      "Chain head reported by the node",
+		},
+		[]string{"coin"},
+	)
+
+	parserParsedHeight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "parsed_height",
+			Help:      "Last parsed block",
+		},
+		[]string{"coin"},
+	)
+
+	parserLagBlocks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "lag_blocks",
+			Help:      "Blocks between the chain head and the last parsed block",
+		},
+		[]string{"coin"},
+	)
+
+	parserFetchDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "fetch_duration_seconds",
+			Help:      "Duration of a single block fetch attempt",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+		},
+		[]string{"coin"},
+	)
+
+	parserFetchErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "fetch_errors_total",
+			Help:      "Failed block fetch attempts, including the retried ones",
+		},
+		[]string{"coin"},
+	)
+
+	parserFailedBlocks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "failed_blocks_total",
+			Help:      "Blocks which failed after all fetch attempts of a parse step",
+		},
+		[]string{"coin"},
+	)
+
+	parserRetriedBlocks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "retried_blocks_total",
+			Help:      "Failed blocks refetched by the background retry, by result including the parked ones",
+		},
+		[]string{"coin", "result"},
+	)
+
+	parserReorgs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "reorgs_total",
+			Help:      "Chain reorganizations rolled back",
+		},
+		[]string{"coin"},
+	)
+
+	parserPublishedTransactions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "published_transactions_total",
+			Help:      "Transactions published to RabbitMQ",
+		},
+		[]string{"coin", "source"},
+	)
+
+	parserPublishedMessages = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "published_messages_total",
+			Help:      "Messages published to RabbitMQ",
+		},
+		[]string{"coin", "source"},
+	)
+
+	parserPollInterval = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "poll_interval_seconds",
+			Help:      "Current poll interval",
+		},
+		[]string{"coin"},
+	)
+
+	parserBlockTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "block_time_seconds",
+			Help:      "Block time learned from the parsed blocks",
+		},
+		[]string{"coin"},
+	)
+
+	parserPanics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "panics_total",
+			Help:      "Panics recovered by the supervisor",
+		},
+		[]string{"coin", "loop"},
+	)
+
+	parserDegraded = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "parser",
+			Name:      "degraded",
+			Help:      "1 while a loop of the coin is restarting after a panic",
+		},
+		[]string{"coin", "loop"},
+	)
+
+	chainHeads   = make(map[string]int64)
+	chainHeadsMu sync.Mutex
+
+	parsedTimes   = make(map[string]int64)
+	parsedTimesMu sync.Mutex
+)
+
+// SetupParser registers the parser metrics and serves them on the address, if any
+func SetupParser(address, path string) {
+	prometheus.MustRegister(
+		parserChainHead,
+		parserParsedHeight,
+		parserLagBlocks,
+		parserFetchDuration,
+		parserFetchErrors,
+		parserFailedBlocks,
+		parserRetriedBlocks,
+		parserReorgs,
+		parserPublishedTransactions,
+		parserPublishedMessages,
+		parserPollInterval,
+		parserBlockTime,
+		parserPanics,
+		parserDegraded,
+	)
+	if address == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/"+path, promhttp.Handler())
+	go func() {
+		if err := http.ListenAndServe(address, mux); err != nil {
+			log.WithFields(log.Fields{"address": address}).Fatal(err)
+		}
+	}()
+}
+
+// RegisterSchedulerQueue exports the number of blocks of the coin waiting for a fetch slot
+func RegisterSchedulerQueue(coin string, depth func() float64) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "parser",
+			Name:        "scheduler_queue_depth",
+			Help:        "Blocks waiting for a fetch slot",
+			ConstLabels: prometheus.Labels{"coin": coin},
+		},
+		depth,
+	))
+}
+
+func ObserveChainHead(coin string, head int64) {
+	chainHeadsMu.Lock()
+	chainHeads[coin] = head
+	chainHeadsMu.Unlock()
+	parserChainHead.WithLabelValues(coin).Set(float64(head))
+}
+
+// ObserveParsedHeight records the last parsed block and its lag behind the last observed chain head
+func ObserveParsedHeight(coin string, height int64) {
+	chainHeadsMu.Lock()
+	head, ok := chainHeads[coin]
+	chainHeadsMu.Unlock()
+
+	parserParsedHeight.WithLabelValues(coin).Set(float64(height))
+	if !ok {
+		return
+	}
+	lag := head - height
+	if lag < 0 {
+		lag = 0
+	}
+	parserLagBlocks.WithLabelValues(coin).Set(float64(lag))
+}
+
+// ObserveParsedTime records the unix timestamp of the last parsed block. The lag in seconds is computed from it
+// when scraped, so it keeps growing while the parser is stuck.
+func ObserveParsedTime(coin string, timestamp int64) {
+	parsedTimesMu.Lock()
+	_, registered := parsedTimes[coin]
+	parsedTimes[coin] = timestamp
+	parsedTimesMu.Unlock()
+	if registered {
+		return
+	}
+
+	prometheus.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "parser",
+			Name:        "lag_seconds",
+			Help:        "Seconds since the timestamp of the last parsed block",
+			ConstLabels: prometheus.Labels{"coin": coin},
+		},
+		func() float64 {
+			return parsedLag(coin, time.Now())
+		},
+	))
+}
+
+func parsedLag(coin string, now time.Time) float64 {
+	parsedTimesMu.Lock()
+	timestamp := parsedTimes[coin]
+	parsedTimesMu.Unlock()
+	lag := now.Unix() - timestamp
+	if lag < 0 {
+		lag = 0
+	}
+	return float64(lag)
+}
+
+func ObservePolling(coin string, interval, blockTime float64) {
+	parserPollInterval.WithLabelValues(coin).Set(interval)
+	parserBlockTime.WithLabelValues(coin).Set(blockTime)
+}
+
+func ObserveFetch(coin string, seconds float64, err error) {
+	parserFetchDuration.WithLabelValues(coin).Observe(seconds)
+	if err != nil {
+		parserFetchErrors.WithLabelValues(coin).Inc()
+	}
+}
+
+func AddFailedBlocks(coin string, count int) {
+	parserFailedBlocks.WithLabelValues(coin).Add(float64(count))
+}
+
+func IncRetriedBlocks(coin, result string) {
+	parserRetriedBlocks.WithLabelValues(coin, result).Inc()
+}
+
+func IncReorgs(coin string) {
+	parserReorgs.WithLabelValues(coin).Inc()
+}
+
+func AddPublished(coin, source string, messages, transactions int) {
+	parserPublishedMessages.WithLabelValues(coin, source).Add(float64(messages))
+	parserPublishedTransactions.WithLabelValues(coin, source).Add(float64(transactions))
+}
+
+func IncPanics(coin, loop string) {
+	parserPanics.WithLabelValues(coin, loop).Inc()
+}
+
+func SetDegraded(coin, loop string, degraded bool) {
+	var value float64
+	if degraded {
+		value = 1
+	}
+	parserDegraded.WithLabelValues(coin, loop).Set(value)
+}
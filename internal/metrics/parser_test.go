@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserveParsedHeight(t *testing.T) {
+	ObserveParsedHeight("lagcoin", 90)
+	assert.Equal(t, float64(90), testutil.ToFloat64(parserParsedHeight.WithLabelValues("lagcoin")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(parserLagBlocks.WithLabelValues("lagcoin")))
+
+	ObserveChainHead("lagcoin", 100)
+	ObserveParsedHeight("lagcoin", 90)
+	assert.Equal(t, float64(100), testutil.ToFloat64(parserChainHead.WithLabelValues("lagcoin")))
+	assert.Equal(t, float64(10), testutil.ToFloat64(parserLagBlocks.WithLabelValues("lagcoin")))
+
+	ObserveParsedHeight("lagcoin", 101)
+	assert.Equal(t, float64(0), testutil.ToFloat64(parserLagBlocks.WithLabelValues("lagcoin")))
+}
+
+func TestObserveParsedTime(t *testing.T) {
+	ObserveParsedTime("lagcoin", 1000)
+	ObserveParsedTime("lagcoin", 1100)
+	assert.Equal(t, float64(30), parsedLag("lagcoin", time.Unix(1130, 0)))
+	assert.Equal(t, float64(0), parsedLag("lagcoin", time.Unix(1000, 0)))
+}
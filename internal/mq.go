@@ -1,24 +1,61 @@
 package internal
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 	"github.com/trustwallet/blockatlas/db"
 	"github.com/trustwallet/golibs/network/mq"
 )
 
 const (
-	// End consumer of published transactions. Not consumed on blockatlas
+	// End consumer of published transactions. Only consumed on blockatlas by the webhooks service
 	TxNotifications mq.Queue = "txNotifications"
 	// Address:coin subscriptions
 	Subscriptions       mq.Queue = "subscriptions"
 	SubscriptionsTokens mq.Queue = "subscriptions_tokens"
+	// Fanout of the subscription events applied by the subscriber, every notifier follows it with its own queue
+	SubscriptionsUpdates mq.Exchange = "subscriptions_updates"
 
 	// Transactions to process, if match subscriptions, pushed to TxNotifications
 	RawTransactions         mq.Queue    = "rawTransactions"
 	RawTokens               mq.Queue    = "rawTokens"
 	RawTransactionsExchange mq.Exchange = "raw_transactions"
+	// Mempool transactions, notified as pending until their block is parsed
+	RawPendingTransactions mq.Queue = "rawPendingTransactions"
+
+	// Set on transactions republished by a backfill
+	ReplayHeader = "x-replay"
+
+	// How long a publish waits for RabbitMQ to confirm the message
+	publishConfirmTimeout = time.Second * 10
+)
+
+var (
+	ErrPublishNacked      = errors.New("publish nacked by RabbitMQ")
+	ErrPublishUnconfirmed = errors.New("publish not confirmed by RabbitMQ in time")
+)
+
+// golibs mq does not expose headers, routing keys and publisher confirms, the connection below backs the
+// declarations needing them and the channels of the publishers
+var (
+	publishConn    *amqp.Connection
+	publishChannel *amqp.Channel
 )
 
+// Publisher publishes on its own channel in confirm mode, so waiting for a confirmation only holds back the
+// publishes of the same publisher. Each loop publishing in order, like a coin outbox relay, keeps its own.
+type Publisher struct {
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+	mutex    sync.Mutex
+	tag      uint64
+}
+
 type ConsumerDatabase struct {
 	Database *db.Instance
 	Delivery func(*db.Instance, amqp.Delivery) error
@@ -28,3 +65,139 @@ type ConsumerDatabase struct {
 func (c ConsumerDatabase) Callback(msg amqp.Delivery) error {
 	return c.Delivery(c.Database, msg)
 }
+
+func initPublisher(url string) error {
+	var err error
+	publishConn, err = amqp.Dial(url)
+	if err != nil {
+		return err
+	}
+	publishChannel, err = publishConn.Channel()
+	if err != nil {
+		return err
+	}
+	go watchPublisher(
+		publishConn.NotifyClose(make(chan *amqp.Error, 1)),
+		publishChannel.NotifyClose(make(chan *amqp.Error, 1)),
+	)
+	return nil
+}
+
+// watchPublisher exits once the publisher connection or channel is lost, the same way mq.FatalWorker does for the
+// golibs connection, so the service restarts with a fresh one instead of failing every publish. A channel closed
+// on purpose reports no error.
+func watchPublisher(connClosed, channelClosed <-chan *amqp.Error) {
+	select {
+	case err := <-connClosed:
+		if err != nil {
+			log.Fatal("MQ publisher connection lost: ", err)
+		}
+	case err := <-channelClosed:
+		if err != nil {
+			log.Fatal("MQ publisher channel lost: ", err)
+		}
+	}
+}
+
+// FollowExchange consumes the exchange with an exclusive queue, deleted along with the channel once ctx is done.
+// Messages published while nobody follows are not kept.
+func FollowExchange(ctx context.Context, exchange mq.Exchange) (<-chan amqp.Delivery, error) {
+	channel, err := publishConn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	queue, err := channel.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.QueueBind(queue.Name, "", string(exchange), false, nil); err != nil {
+		return nil, err
+	}
+	deliveries, err := channel.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		_ = channel.Close()
+	}()
+	return deliveries, nil
+}
+
+// NewPublisher opens a publisher channel on the publisher connection, the service exits if the channel is lost
+func NewPublisher() (*Publisher, error) {
+	channel, err := publishConn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := channel.Confirm(false); err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+	go watchPublisher(nil, channel.NotifyClose(make(chan *amqp.Error, 1)))
+	return &Publisher{
+		channel:  channel,
+		confirms: channel.NotifyPublish(make(chan amqp.Confirmation, 16)),
+	}, nil
+}
+
+// PublishWithRoutingKey publishes to the exchange with a routing key and headers, both unsupported by golibs mq.
+// It returns once RabbitMQ confirmed the message, and an error if it was nacked or not confirmed in time.
+func (p *Publisher) PublishWithRoutingKey(exchange mq.Exchange, routingKey string, headers amqp.Table, body []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	err := p.channel.Publish(string(exchange), routingKey, false, false, amqp.Publishing{
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		ContentType:  "text/plain",
+		Body:         body,
+	})
+	if err != nil {
+		return err
+	}
+	p.tag++
+	return waitConfirm(p.confirms, p.tag, publishConfirmTimeout)
+}
+
+func (p *Publisher) Close() error {
+	return p.channel.Close()
+}
+
+// waitConfirm waits for the confirmation of the delivery tag, skipping the late ones of publishes which timed out
+func waitConfirm(confirms <-chan amqp.Confirmation, tag uint64, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case confirm, ok := <-confirms:
+			if !ok {
+				return amqp.ErrClosed
+			}
+			if confirm.DeliveryTag < tag {
+				continue
+			}
+			if !confirm.Ack {
+				return ErrPublishNacked
+			}
+			return nil
+		case <-timer.C:
+			return ErrPublishUnconfirmed
+		}
+	}
+}
+
+// BindWithRoutingKeys binds the queue to the exchange once per routing key
+func BindWithRoutingKeys(exchange mq.Exchange, queue mq.Queue, routingKeys []string) error {
+	for _, key := range routingKeys {
+		if err := publishChannel.QueueBind(string(queue), key, string(exchange), false, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func IsReplay(delivery amqp.Delivery) bool {
+	replay, ok := delivery.Headers[ReplayHeader].(bool)
+	return ok && replay
+}
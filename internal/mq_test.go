@@ -0,0 +1,24 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitConfirm(t *testing.T) {
+	confirms := make(chan amqp.Confirmation, 2)
+	confirms <- amqp.Confirmation{DeliveryTag: 1, Ack: true}
+	confirms <- amqp.Confirmation{DeliveryTag: 2, Ack: true}
+	assert.Nil(t, waitConfirm(confirms, 2, time.Second))
+
+	confirms <- amqp.Confirmation{DeliveryTag: 3, Ack: false}
+	assert.Equal(t, ErrPublishNacked, waitConfirm(confirms, 3, time.Second))
+
+	assert.Equal(t, ErrPublishUnconfirmed, waitConfirm(confirms, 4, time.Millisecond))
+
+	close(confirms)
+	assert.Equal(t, amqp.ErrClosed, waitConfirm(confirms, 5, time.Second))
+}
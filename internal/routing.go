@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+// AllTransactionsRoutingKey binds a queue to every coin and transaction type
+const AllTransactionsRoutingKey = "#"
+
+// TransactionsRoutingKey routes a transaction on RawTransactionsExchange by coin handle and type,
+// for example ethereum.token_transfer
+func TransactionsRoutingKey(tx types.Tx) string {
+	handle := strconv.Itoa(int(tx.Coin))
+	if c, ok := coin.Coins[tx.Coin]; ok {
+		handle = c.Handle
+	}
+	return fmt.Sprintf("%s.%s", handle, TransactionType(tx))
+}
+
+// GroupByRoutingKey splits the transactions by routing key, keeping their order, and returns the keys sorted
+func GroupByRoutingKey(txs types.Txs) (map[string]types.Txs, []string) {
+	groups := make(map[string]types.Txs)
+	keys := make([]string, 0)
+	for _, tx := range txs {
+		key := TransactionsRoutingKey(tx)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], tx)
+	}
+	sort.Strings(keys)
+	return groups, keys
+}
+
+// TransactionType mirrors the type set by types.Tx marshalling, which normalized transactions may not have yet
+func TransactionType(tx types.Tx) types.TransactionType {
+	switch tx.Meta.(type) {
+	case types.Transfer, *types.Transfer:
+		return types.TxTransfer
+	case types.MultiCurrencyTransfer, *types.MultiCurrencyTransfer:
+		return types.TxMultiCurrencyTransfer
+	case types.NativeTokenTransfer, *types.NativeTokenTransfer:
+		return types.TxNativeTokenTransfer
+	case types.TokenTransfer, *types.TokenTransfer:
+		return types.TxTokenTransfer
+	case types.CollectibleTransfer, *types.CollectibleTransfer:
+		return types.TxCollectibleTransfer
+	case types.TokenSwap, *types.TokenSwap:
+		return types.TxTokenSwap
+	case types.ContractCall, *types.ContractCall:
+		return types.TxContractCall
+	case types.AnyAction, *types.AnyAction:
+		return types.TxAnyAction
+	}
+	if tx.Type != "" {
+		return tx.Type
+	}
+	return "unknown"
+}
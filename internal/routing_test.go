@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestTransactionsRoutingKey(t *testing.T) {
+	assert.Equal(t, "ethereum.transfer", TransactionsRoutingKey(types.Tx{Coin: coin.ETHEREUM, Meta: types.Transfer{}}))
+	assert.Equal(t, "ethereum.token_transfer", TransactionsRoutingKey(types.Tx{Coin: coin.ETHEREUM, Meta: &types.TokenTransfer{}}))
+	assert.Equal(t, "binance.native_token_transfer", TransactionsRoutingKey(types.Tx{Coin: coin.BINANCE, Meta: types.NativeTokenTransfer{}}))
+	assert.Equal(t, "tron.contract_call", TransactionsRoutingKey(types.Tx{Coin: coin.TRON, Type: types.TxContractCall}))
+}
+
+func TestGroupByRoutingKey(t *testing.T) {
+	txs := types.Txs{
+		{ID: "1", Coin: coin.ETHEREUM, Meta: types.TokenTransfer{}},
+		{ID: "2", Coin: coin.BITCOIN, Meta: types.Transfer{}},
+		{ID: "3", Coin: coin.ETHEREUM, Meta: types.TokenTransfer{}},
+	}
+	groups, keys := GroupByRoutingKey(txs)
+	assert.Equal(t, []string{"bitcoin.transfer", "ethereum.token_transfer"}, keys)
+	assert.Len(t, groups["ethereum.token_transfer"], 2)
+	assert.Equal(t, "1", groups["ethereum.token_transfer"][0].ID)
+	assert.Equal(t, "3", groups["ethereum.token_transfer"][1].ID)
+}
@@ -0,0 +1,11 @@
+package blockatlas
+
+type (
+	// BlockHeader links a block to its parent and is used to detect chain reorganizations
+	BlockHeader struct {
+		Number     int64  `json:"number"`
+		Hash       string `json:"hash"`
+		ParentHash string `json:"parent_hash"`
+		Time       int64  `json:"time"`
+	}
+)
@@ -6,6 +6,14 @@ type (
 	ResultsResponse struct {
 		Results interface{} `json:"docs"`
 	}
+
+	// XpubAddress is an address derived from an extended public key at Change/Index
+	XpubAddress struct {
+		Address string
+		Change  bool
+		Index   int
+		Used    bool
+	}
 )
 
 func MapJsonObject(from interface{}, to interface{}) error {
@@ -1,6 +1,8 @@
 package blockatlas
 
 import (
+	"context"
+
 	"github.com/trustwallet/golibs/coin"
 	"github.com/trustwallet/golibs/types"
 )
@@ -18,6 +20,19 @@ type (
 		GetBlockByNumber(num int64) (*types.Block, error)
 	}
 
+	// BlockHeaderAPI provides blocks together with their hash and parent hash
+	BlockHeaderAPI interface {
+		BlockAPI
+		GetBlockWithHeader(num int64) (*types.Block, *BlockHeader, error)
+	}
+
+	// PendingTxAPI streams transactions as they are broadcast, before they are included in a block
+	PendingTxAPI interface {
+		Platform
+		// SubscribePendingTxs calls handle for every new mempool transaction until the context is done or the stream fails
+		SubscribePendingTxs(ctx context.Context, handle func(types.Tx)) error
+	}
+
 	// TxAPI provides transaction lookups based on address
 	TxAPI interface {
 		Platform
@@ -36,6 +51,13 @@ type (
 		GetTxsByXpub(xpub string) (types.Txs, error)
 	}
 
+	// XpubAPI derives the addresses of extended public keys, for subscriptions to HD wallets
+	XpubAPI interface {
+		TxUtxoAPI
+		// GetXpubAddresses returns the derived addresses up to gap unused ones past the last used of each chain
+		GetXpubAddresses(xpub string, gap int) ([]XpubAddress, error)
+	}
+
 	// TokensAPI provides token lookups
 	TokensAPI interface {
 		Platform
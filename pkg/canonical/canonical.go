@@ -0,0 +1,81 @@
+// Package canonical rewrites addresses to the one form subscriptions are stored and matched in, per coin
+package canonical
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+// Canonicalizer returns the canonical form of an address, or the address as is if it can't parse it
+type Canonicalizer func(address string) string
+
+var canonicalizers = map[uint]Canonicalizer{
+	coin.ETHEREUM:     hexLowercase,
+	coin.CLASSIC:      hexLowercase,
+	coin.POA:          hexLowercase,
+	coin.CALLISTO:     hexLowercase,
+	coin.GOCHAIN:      hexLowercase,
+	coin.THUNDERTOKEN: hexLowercase,
+	coin.TOMOCHAIN:    hexLowercase,
+	coin.WANCHAIN:     hexLowercase,
+	coin.SMARTCHAIN:   hexLowercase,
+	coin.BITCOINCASH:  cashAddress,
+	coin.BITCOIN:      segwitLowercase("bc"),
+	coin.LITECOIN:     segwitLowercase("ltc"),
+	coin.DIGIBYTE:     segwitLowercase("dgb"),
+	coin.VIACOIN:      segwitLowercase("via"),
+	coin.GROESTLCOIN:  segwitLowercase("grs"),
+	coin.QTUM:         segwitLowercase("qc"),
+}
+
+// Address returns the canonical form of the address on the coin, addresses of other coins are kept as is
+func Address(coinID uint, address string) string {
+	canonicalize, ok := canonicalizers[coinID]
+	if !ok {
+		return address
+	}
+	return canonicalize(address)
+}
+
+// AddressID is types.GetAddressID with the canonical address
+func AddressID(coinID, address string) string {
+	id, err := strconv.ParseUint(coinID, 10, 32)
+	if err != nil {
+		return types.GetAddressID(coinID, address)
+	}
+	return types.GetAddressID(coinID, Address(uint(id), address))
+}
+
+// Subscriptions returns the subscriptions with their canonical addresses, followed by their normalized memo if any
+func Subscriptions(subscriptions []types.Subscription) []types.Subscription {
+	result := make([]types.Subscription, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		address, m := memo.Split(subscription.Coin, subscription.Address)
+		subscription.Address = memo.Join(Address(subscription.Coin, address), m)
+		result = append(result, subscription)
+	}
+	return result
+}
+
+// hexLowercase drops the EIP-55 checksum case of 0x addresses
+func hexLowercase(address string) string {
+	if len(address) != 42 || !strings.HasPrefix(strings.ToLower(address), "0x") {
+		return address
+	}
+	return strings.ToLower(address)
+}
+
+// segwitLowercase lowercases bech32 addresses of the hrp, which are valid in either case. Base58 addresses are case sensitive.
+func segwitLowercase(hrp string) Canonicalizer {
+	return func(address string) string {
+		lower := strings.ToLower(address)
+		if !strings.HasPrefix(lower, hrp+"1") || (address != lower && address != strings.ToUpper(address)) {
+			return address
+		}
+		return lower
+	}
+}
@@ -0,0 +1,53 @@
+package canonical
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		coin    uint
+		address string
+		want    string
+	}{
+		{"ethereum checksum", coin.ETHEREUM, "0x08777CB1e80F45642752662B04886Df2d271E049", "0x08777cb1e80f45642752662b04886df2d271e049"},
+		{"smartchain lowercase", coin.SMARTCHAIN, "0x08777cb1e80f45642752662b04886df2d271e049", "0x08777cb1e80f45642752662b04886df2d271e049"},
+		{"ethereum invalid", coin.ETHEREUM, "ABC", "ABC"},
+		{"bitcoincash prefixed", coin.BITCOINCASH, "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"bitcoincash uppercase", coin.BITCOINCASH, "BITCOINCASH:QPM2QSZNHKS23Z7629MMS6S4CWEF74VCWVY22GDX6A", "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"bitcoincash cashaddr", coin.BITCOINCASH, "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"bitcoincash legacy p2pkh", coin.BITCOINCASH, "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{"bitcoincash legacy p2sh", coin.BITCOINCASH, "3CWFddi6m4ndiGyKqzYvsFYagqDLPVMTzC", "ppm2qsznhks23z7629mms6s4cwef74vcwvn0h829pq"},
+		{"bitcoin bech32 uppercase", coin.BITCOIN, "BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"bitcoin base58", coin.BITCOIN, "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu", "1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu"},
+		{"other coin", coin.BINANCE, "bnb1ABC", "bnb1ABC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Address(tt.coin, tt.address))
+		})
+	}
+}
+
+func TestAddressID(t *testing.T) {
+	assert.Equal(t, "60_0x08777cb1e80f45642752662b04886df2d271e049", AddressID("60", "0x08777CB1e80F45642752662B04886Df2d271E049"))
+	assert.Equal(t, "eth_0xABC", AddressID("eth", "0xABC"))
+}
+
+func TestSubscriptions(t *testing.T) {
+	subscriptions := Subscriptions([]types.Subscription{{Coin: coin.ETHEREUM, Address: "0xAbC0000000000000000000000000000000000000"}})
+	assert.Equal(t, []types.Subscription{{Coin: coin.ETHEREUM, Address: "0xabc0000000000000000000000000000000000000"}}, subscriptions)
+
+	subscriptions = Subscriptions([]types.Subscription{
+		{Coin: coin.RIPPLE, Address: "rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1:0042"},
+		{Coin: coin.BITCOINCASH, Address: "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+	})
+	assert.Equal(t, "rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1:42", subscriptions[0].Address)
+	assert.Equal(t, "144_rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1:42", subscriptions[0].AddressID())
+	assert.Equal(t, "qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", subscriptions[1].Address)
+}
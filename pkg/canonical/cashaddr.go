@@ -0,0 +1,98 @@
+package canonical
+
+import (
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+const (
+	cashAddressPrefix  = "bitcoincash"
+	cashAddressCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+	legacyPubKeyHash = 0x00
+	legacyScriptHash = 0x05
+)
+
+// cashAddress returns the lowercase cashaddr of Bitcoin Cash addresses without the bitcoincash: prefix,
+// legacy addresses are converted
+func cashAddress(address string) string {
+	lower := strings.ToLower(address)
+	if strings.HasPrefix(lower, cashAddressPrefix+":") {
+		return strings.TrimPrefix(lower, cashAddressPrefix+":")
+	}
+	if strings.HasPrefix(lower, "q") || strings.HasPrefix(lower, "p") {
+		if address == lower || address == strings.ToUpper(address) {
+			return lower
+		}
+		return address
+	}
+
+	hash, version, err := base58.CheckDecode(address)
+	if err != nil || len(hash) != 20 {
+		return address
+	}
+	switch version {
+	case legacyPubKeyHash:
+		return encodeCashAddress(0, hash)
+	case legacyScriptHash:
+		return encodeCashAddress(1, hash)
+	}
+	return address
+}
+
+// encodeCashAddress encodes a 160 bits hash of the type, 0 for P2PKH and 1 for P2SH, without the prefix
+func encodeCashAddress(addressType byte, hash []byte) string {
+	payload := convertBits(append([]byte{addressType << 3}, hash...))
+
+	values := make([]byte, 0, len(cashAddressPrefix)+1+len(payload)+8)
+	for i := 0; i < len(cashAddressPrefix); i++ {
+		values = append(values, cashAddressPrefix[i]&0x1f)
+	}
+	values = append(values, 0)
+	values = append(values, payload...)
+	values = append(values, make([]byte, 8)...)
+	checksum := cashAddressPolymod(values) ^ 1
+
+	var result strings.Builder
+	for _, value := range payload {
+		result.WriteByte(cashAddressCharset[value])
+	}
+	for i := 0; i < 8; i++ {
+		result.WriteByte(cashAddressCharset[(checksum>>(5*(7-uint(i))))&0x1f])
+	}
+	return result.String()
+}
+
+// convertBits regroups 8 bits bytes into 5 bits values, padding the last one
+func convertBits(data []byte) []byte {
+	result := make([]byte, 0, (len(data)*8+4)/5)
+	var acc, bits uint
+	for _, b := range data {
+		acc = acc<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			result = append(result, byte(acc>>bits)&0x1f)
+		}
+	}
+	if bits > 0 {
+		result = append(result, byte(acc<<(5-bits))&0x1f)
+	}
+	return result
+}
+
+func cashAddressPolymod(values []byte) uint64 {
+	generators := [5]uint64{0x98f2bc8e61, 0x79b76d99e2, 0xf33e5fb3c4, 0xae2eabe2a8, 0x1e4f43e470}
+	c := uint64(1)
+	for _, value := range values {
+		c0 := c >> 35
+		c = ((c & 0x07ffffffff) << 5) ^ uint64(value)
+		for i, generator := range generators {
+			if c0&(1<<uint(i)) != 0 {
+				c ^= generator
+			}
+		}
+	}
+	return c
+}
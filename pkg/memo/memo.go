@@ -0,0 +1,108 @@
+// Package memo normalizes the memos and destination tags shared deposit addresses tell their customers apart
+// with, so subscriptions scoped to a memo match the transactions carrying it
+package memo
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+// Separator separates the memo from the address of a memo scoped subscription, e.g. "rAddress:12345"
+const Separator = ":"
+
+// Normalizer returns the form a memo of the coin is matched in, and false if the memo can't scope a subscription
+type Normalizer func(memo string) (string, bool)
+
+var normalizers = map[uint]Normalizer{
+	coin.RIPPLE:  destinationTag,
+	coin.STELLAR: text,
+	coin.BINANCE: text,
+}
+
+// Supported reports whether subscriptions of the coin can be scoped to a memo
+func Supported(coinID uint) bool {
+	_, ok := normalizers[coinID]
+	return ok
+}
+
+// Normalize returns the normalized memo of the coin, or an empty memo if the coin has none or it is invalid
+func Normalize(coinID uint, memo string) string {
+	normalize, ok := normalizers[coinID]
+	if !ok {
+		return ""
+	}
+	normalized, ok := normalize(memo)
+	if !ok {
+		return ""
+	}
+	return normalized
+}
+
+// Split separates the normalized memo from the address of a subscription of the coin. Addresses of coins
+// without memos are kept as is.
+func Split(coinID uint, address string) (string, string) {
+	if !Supported(coinID) {
+		return address, ""
+	}
+	parts := strings.SplitN(address, Separator, 2)
+	if len(parts) != 2 {
+		return address, ""
+	}
+	return parts[0], Normalize(coinID, parts[1])
+}
+
+// SplitAddressID separates the memo from an address id, as built by types.Subscription.AddressID
+func SplitAddressID(addressID string) (string, string) {
+	parts := strings.SplitN(addressID, "_", 2)
+	if len(parts) != 2 {
+		return addressID, ""
+	}
+	coinID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return addressID, ""
+	}
+	address, memo := Split(uint(coinID), parts[1])
+	return parts[0] + "_" + address, memo
+}
+
+// Join appends the memo to the address, an empty memo leaves the address as is
+func Join(address, memo string) string {
+	if memo == "" {
+		return address
+	}
+	return address + Separator + memo
+}
+
+// FilterTransactions is types.Txs.FilterTransactionsByMemo keeping the normalized memos of the coins
+// subscriptions can be scoped with, the other coins only keep numeric memos
+func FilterTransactions(txs types.Txs) types.Txs {
+	result := make(types.Txs, 0, len(txs))
+	for _, tx := range txs {
+		switch {
+		case Supported(tx.Coin):
+			tx.Memo = Normalize(tx.Coin, tx.Memo)
+		case !types.AllowMemo(tx.Memo):
+			tx.Memo = ""
+		}
+		result = append(result, tx)
+	}
+	return result
+}
+
+// destinationTag is a Ripple destination tag, an unsigned 32 bit integer
+func destinationTag(memo string) (string, bool) {
+	tag, err := strconv.ParseUint(strings.TrimSpace(memo), 10, 32)
+	if err != nil {
+		return "", false
+	}
+	return strconv.FormatUint(tag, 10), true
+}
+
+// text is a free form memo, matched without its surrounding spaces
+func text(memo string) (string, bool) {
+	trimmed := strings.TrimSpace(memo)
+	return trimmed, trimmed != ""
+}
@@ -0,0 +1,68 @@
+package memo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		coin uint
+		memo string
+		want string
+	}{
+		{"ripple tag", coin.RIPPLE, "12345", "12345"},
+		{"ripple padded tag", coin.RIPPLE, " 0012345 ", "12345"},
+		{"ripple text", coin.RIPPLE, "abc", ""},
+		{"ripple tag overflow", coin.RIPPLE, "4294967296", ""},
+		{"stellar text", coin.STELLAR, " deposit 1 ", "deposit 1"},
+		{"stellar id", coin.STELLAR, "1234567890123", "1234567890123"},
+		{"binance memo", coin.BINANCE, "103125897", "103125897"},
+		{"binance blank", coin.BINANCE, "  ", ""},
+		{"unsupported coin", coin.ETHEREUM, "123", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Normalize(tt.coin, tt.memo))
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	address, memo := Split(coin.RIPPLE, "rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1:02500")
+	assert.Equal(t, "rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1", address)
+	assert.Equal(t, "2500", memo)
+
+	address, memo = Split(coin.STELLAR, "GAX3BRBNB5WTJ2GNEFFH7A4CZKT2FORYABDDBZR5FIIT3P7FLS2EFOZZ:a:b")
+	assert.Equal(t, "GAX3BRBNB5WTJ2GNEFFH7A4CZKT2FORYABDDBZR5FIIT3P7FLS2EFOZZ", address)
+	assert.Equal(t, "a:b", memo)
+
+	address, memo = Split(coin.BITCOINCASH, "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a")
+	assert.Equal(t, "bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a", address)
+	assert.Empty(t, memo)
+
+	address, memo = SplitAddressID("144_rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1:2500")
+	assert.Equal(t, "144_rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1", address)
+	assert.Equal(t, "2500", memo)
+	assert.Equal(t, "144_rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1:2500", Join(address, memo))
+
+	address, memo = SplitAddressID("144_rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1")
+	assert.Equal(t, "144_rMQ98K56yXJbDGv49ZSmW51sLn94Xe1mu1", Join(address, memo))
+}
+
+func TestFilterTransactions(t *testing.T) {
+	txs := FilterTransactions(types.Txs{
+		{ID: "1", Coin: coin.STELLAR, Memo: " deposit "},
+		{ID: "2", Coin: coin.ETHEREUM, Memo: "spam"},
+		{ID: "3", Coin: coin.COSMOS, Memo: "42"},
+		{ID: "4", Coin: coin.RIPPLE, Memo: "x"},
+	})
+	assert.Equal(t, "deposit", txs[0].Memo)
+	assert.Equal(t, "", txs[1].Memo)
+	assert.Equal(t, "42", txs[2].Memo)
+	assert.Equal(t, "", txs[3].Memo)
+}
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/trustwallet/golibs/types"
 
@@ -172,7 +173,7 @@ func normalizeMultiTransferTransaction(t Tx) types.Txs {
 			Block:    uint64(t.BlockHeight),
 			Status:   types.StatusCompleted,
 			Sequence: uint64(t.Sequence),
-			Memo:     t.Memo,
+			Memo:     normalizeMemo(t.Memo),
 		}
 		switch {
 		case subTx.TxAsset == BNBAsset:
@@ -211,10 +212,22 @@ func normalizeBaseOfTransaction(t Tx) types.Tx {
 		Block:    uint64(t.BlockHeight),
 		Status:   types.StatusCompleted,
 		Sequence: uint64(t.Sequence),
-		Memo:     t.Memo,
+		Memo:     normalizeMemo(t.Memo),
 	}
 }
 
+// normalizeMemo drops the control and invisible format characters wallets leave in memos, and the surrounding
+// spaces, so a memo scoped subscription matches the memo the customer was given
+func normalizeMemo(memo string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, memo)
+	return strings.TrimSpace(cleaned)
+}
+
 func normalizeTokens(srcBalance []TokenBalance, tokens Tokens) []types.Token {
 	assetIds := make([]types.Token, 0)
 	for _, srcToken := range srcBalance {
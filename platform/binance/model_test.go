@@ -1,6 +1,10 @@
 package binance
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
 
 func Test_isZeroBalance(t *testing.T) {
 	type testZeroStruct struct {
@@ -33,3 +37,12 @@ func Test_isZeroBalance(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeMemo(t *testing.T) {
+	assert.Equal(t, "", normalizeMemo(""))
+	assert.Equal(t, "104345678", normalizeMemo("104345678"))
+	assert.Equal(t, "104345678", normalizeMemo(" 104345678\n"))
+	assert.Equal(t, "104345678", normalizeMemo("\u200b104345678\ufeff"))
+	assert.Equal(t, "deposit 42", normalizeMemo("deposit\x00 42"))
+	assert.Equal(t, "Memo", normalizeMemo("Memo"))
+}
@@ -1,6 +1,10 @@
 package bitcoin
 
 import (
+	"strconv"
+	"strings"
+
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
 	"github.com/trustwallet/blockatlas/platform/bitcoin/blockbook"
 	"github.com/trustwallet/golibs/client"
 	"github.com/trustwallet/golibs/coin"
@@ -31,3 +35,34 @@ func (p *Platform) GetAddressesFromXpub(xpub string) ([]string, error) {
 	}
 	return addresses, err
 }
+
+func (p *Platform) GetXpubAddresses(xpub string, gap int) ([]blockatlas.XpubAddress, error) {
+	tokens, err := p.client.GetXpubTokens(xpub, gap)
+	if err != nil {
+		return nil, err
+	}
+	return xpubAddresses(tokens), nil
+}
+
+// xpubAddresses reads the chain and index of the derived addresses from their path, m/purpose'/coin'/account'/change/index
+func xpubAddresses(tokens []blockbook.Token) []blockatlas.XpubAddress {
+	addresses := make([]blockatlas.XpubAddress, 0, len(tokens))
+	for _, token := range tokens {
+		parts := strings.Split(token.Path, "/")
+		if token.Name == "" || len(parts) < 2 {
+			continue
+		}
+		change, errChange := strconv.Atoi(parts[len(parts)-2])
+		index, errIndex := strconv.Atoi(parts[len(parts)-1])
+		if errChange != nil || errIndex != nil {
+			continue
+		}
+		addresses = append(addresses, blockatlas.XpubAddress{
+			Address: token.Name,
+			Change:  change == 1,
+			Index:   index,
+			Used:    token.Transfers > 0,
+		})
+	}
+	return addresses
+}
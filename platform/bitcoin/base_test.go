@@ -0,0 +1,24 @@
+package bitcoin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/platform/bitcoin/blockbook"
+)
+
+func TestXpubAddresses(t *testing.T) {
+	tokens := []blockbook.Token{
+		{Name: "bc1qfrjtxm8g20g97qzgadg7v9s3ftjkq02qfssk87", Path: "m/84'/0'/0'/0/0", Transfers: 2},
+		{Name: "bc1qxhx6xl5vh6g4rw4hmnaqsu6crl2kyfsxp3n6e9", Path: "m/84'/0'/0'/0/1"},
+		{Name: "bc1q4xk5ha2ufkqfqt3sy7wh5yxwjhyl4umg2l0l6z", Path: "m/84'/0'/0'/1/0", Transfers: 1},
+		{Name: "", Path: "m/84'/0'/0'/1/1"},
+		{Name: "bc1qinvalid", Path: "derived"},
+	}
+	assert.Equal(t, []blockatlas.XpubAddress{
+		{Address: "bc1qfrjtxm8g20g97qzgadg7v9s3ftjkq02qfssk87", Index: 0, Used: true},
+		{Address: "bc1qxhx6xl5vh6g4rw4hmnaqsu6crl2kyfsxp3n6e9", Index: 1},
+		{Address: "bc1q4xk5ha2ufkqfqt3sy7wh5yxwjhyl4umg2l0l6z", Change: true, Index: 0, Used: true},
+	}, xpubAddresses(tokens))
+}
@@ -1,15 +1,24 @@
 package bitcoin
 
-import "github.com/trustwallet/golibs/types"
+import (
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/platform/bitcoin/blockbook"
+	"github.com/trustwallet/golibs/types"
+)
 
 func (p *Platform) CurrentBlockNumber() (int64, error) {
 	return p.client.GetCurrentBlockNumber()
 }
 
 func (p *Platform) GetBlockByNumber(num int64) (*types.Block, error) {
-	block, err := p.client.GetAllTransactionsByBlockNumber(num)
+	block, _, err := p.GetBlockWithHeader(num)
+	return block, err
+}
+
+func (p *Platform) GetBlockWithHeader(num int64) (*types.Block, *blockatlas.BlockHeader, error) {
+	page, block, err := p.client.GetAllTransactionsWithBlockByNumber(num)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var normalized types.Txs
 	for _, tx := range block {
@@ -18,6 +27,5 @@ func (p *Platform) GetBlockByNumber(num int64) (*types.Block, error) {
 	return &types.Block{
 		Number: num,
 		Txs:    normalized,
-	}, nil
-
+	}, blockbook.NormalizeBlockHeader(page, num), nil
 }
@@ -3,6 +3,7 @@ package blockbook
 import (
 	"strings"
 
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
 	"github.com/trustwallet/golibs/types"
 )
 
@@ -11,13 +12,18 @@ const (
 )
 
 func (c *Client) GetBlockByNumber(num int64, coinIndex uint) (*types.Block, error) {
-	block, err := c.GetAllTransactionsByBlockNumber(num)
+	block, _, err := c.GetBlockWithHeaderByNumber(num, coinIndex)
+	return block, err
+}
+
+func (c *Client) GetBlockWithHeaderByNumber(num int64, coinIndex uint) (*types.Block, *blockatlas.BlockHeader, error) {
+	page, block, err := c.GetAllTransactionsWithBlockByNumber(num)
 	if err != nil {
 		err2, ok := err.(*ClientError)
 		if ok && strings.HasPrefix(err2.Error(), transactionError) {
-			return &types.Block{Number: num, Txs: types.Txs{}}, nil
+			return &types.Block{Number: num, Txs: types.Txs{}}, nil, nil
 		}
-		return nil, err
+		return nil, nil, err
 	}
 	txs := make(types.Txs, 0)
 	for _, srcTx := range block {
@@ -26,5 +32,18 @@ func (c *Client) GetBlockByNumber(num int64, coinIndex uint) (*types.Block, erro
 	return &types.Block{
 		Number: num,
 		Txs:    txs,
-	}, nil
+	}, NormalizeBlockHeader(page, num), nil
+}
+
+// NormalizeBlockHeader returns nil when the block page carries no hash
+func NormalizeBlockHeader(page TransactionsList, num int64) *blockatlas.BlockHeader {
+	if page.Hash == "" {
+		return nil
+	}
+	return &blockatlas.BlockHeader{
+		Number:     num,
+		Hash:       page.Hash,
+		ParentHash: page.PreviousHash,
+		Time:       page.Time,
+	}
 }
@@ -0,0 +1,25 @@
+package blockbook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+)
+
+func TestNormalizeBlockHeader(t *testing.T) {
+	page := TransactionsList{
+		Hash:         "00000000000000000007878ec04bb2b2e12317804810f4c26033585b3f81ffaa",
+		PreviousHash: "0000000000000000000ad7ab2ea4aa2cf9bed2a5d0e5cfc86d0b9c5a6da4e4dd",
+		Height:       647450,
+		Time:         1599212445,
+	}
+	assert.Equal(t, &blockatlas.BlockHeader{
+		Number:     647450,
+		Hash:       "00000000000000000007878ec04bb2b2e12317804810f4c26033585b3f81ffaa",
+		ParentHash: "0000000000000000000ad7ab2ea4aa2cf9bed2a5d0e5cfc86d0b9c5a6da4e4dd",
+		Time:       1599212445,
+	}, NormalizeBlockHeader(page, 647450))
+
+	assert.Nil(t, NormalizeBlockHeader(TransactionsList{}, 647450))
+}
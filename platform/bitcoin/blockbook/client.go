@@ -52,6 +52,12 @@ func (c *Client) GetTokens(address string) ([]Token, error) {
 
 // Transactions
 func (c *Client) GetAllTransactionsByBlockNumber(num int64) ([]Transaction, error) {
+	_, txs, err := c.GetAllTransactionsWithBlockByNumber(num)
+	return txs, err
+}
+
+// GetAllTransactionsWithBlockByNumber returns the first block page, which carries the block hashes, and all block transactions
+func (c *Client) GetAllTransactionsWithBlockByNumber(num int64) (TransactionsList, []Transaction, error) {
 	page := int64(1)
 	block, err := c.GetTransactionsByBlockNumber(num, page)
 	if err != nil {
@@ -60,14 +66,14 @@ func (c *Client) GetAllTransactionsByBlockNumber(num int64) ([]Transaction, erro
 			var clientError ClientError
 			err2 := json.Unmarshal(httpError.Body, &clientError)
 			if err2 == nil {
-				return nil, &clientError
+				return block, nil, &clientError
 			}
 		}
-		return nil, err
+		return block, nil, err
 	}
 	txPages := c.getAllBlockPages(block.TotalPages, num)
 	txs := append(txPages, block.TransactionList()...)
-	return txs, nil
+	return block, txs, nil
 }
 
 func (c *Client) GetTxs(address string) (TransactionsList, error) {
@@ -121,6 +127,19 @@ func (c *Client) GetAddressesFromXpub(xpub string) (tokens []Token, err error) {
 	return transactions.Tokens, err
 }
 
+// GetXpubTokens returns the addresses derived from the xpub, blockbook derives gap unused addresses past the last used
+func (c *Client) GetXpubTokens(xpub string, gap int) (tokens []Token, err error) {
+	path := fmt.Sprintf("api/v2/xpub/%s", xpub)
+	args := url.Values{
+		"details": {"tokens"},
+		"tokens":  {"derived"},
+		"gap":     {strconv.Itoa(gap)},
+	}
+	var transactions TransactionsList
+	err = c.Get(&transactions, path, args)
+	return transactions.Tokens, err
+}
+
 func (c *Client) getAllBlockPages(total, num int64) []Transaction {
 	txs := make([]Transaction, 0)
 	if total <= 1 {
@@ -35,6 +35,9 @@ type Token struct {
 	Name     string          `json:"name"`
 	Symbol   string          `json:"symbol"`
 	Type     types.TokenType `json:"type"`
+	// Path and Transfers are set on the addresses derived from an xpub
+	Path      string `json:"path,omitempty"`
+	Transfers int    `json:"transfers,omitempty"`
 }
 
 // EthereumSpecific contains ethereum specific transaction data
@@ -56,6 +59,9 @@ type TransactionsList struct {
 	Tokens       []Token       `json:"tokens,omitempty"`
 	TxCount      int64         `json:"txCount,omitempty"`
 	Hash         string        `json:"hash,omitempty"`
+	PreviousHash string        `json:"previousBlockHash,omitempty"`
+	Height       int64         `json:"height,omitempty"`
+	Time         int64         `json:"time,omitempty"`
 }
 
 func (tl *TransactionsList) TransactionList() []Transaction {
@@ -0,0 +1,109 @@
+package blockbook
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/trustwallet/golibs/types"
+	"golang.org/x/net/websocket"
+)
+
+const subscribeNewTransaction = "subscribeNewTransaction"
+
+type (
+	wsRequest struct {
+		ID     string      `json:"id"`
+		Method string      `json:"method"`
+		Params interface{} `json:"params"`
+	}
+
+	wsResponse struct {
+		ID   string          `json:"id"`
+		Data json.RawMessage `json:"data"`
+	}
+
+	wsSubscribed struct {
+		Subscribed *bool  `json:"subscribed"`
+		Error      string `json:"error"`
+	}
+)
+
+// SubscribeNewTransactions streams the transactions entering the mempool from the blockbook websocket API
+// until the context is done or the connection fails. Blockbook has to run with -enablesubnewtx.
+func (c *Client) SubscribeNewTransactions(ctx context.Context, handle func(Transaction)) error {
+	location, err := websocketURL(c.BaseUrl)
+	if err != nil {
+		return err
+	}
+	conn, err := websocket.Dial(location, "", c.BaseUrl)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	request := wsRequest{ID: subscribeNewTransaction, Method: subscribeNewTransaction, Params: struct{}{}}
+	if err := websocket.JSON.Send(conn, request); err != nil {
+		return err
+	}
+
+	for {
+		var response wsResponse
+		if err := websocket.JSON.Receive(conn, &response); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if response.ID != subscribeNewTransaction {
+			continue
+		}
+
+		var subscribed wsSubscribed
+		if err := json.Unmarshal(response.Data, &subscribed); err == nil && (subscribed.Subscribed != nil || subscribed.Error != "") {
+			if subscribed.Error != "" {
+				return &ClientError{Err: subscribed.Error}
+			}
+			continue
+		}
+
+		var tx Transaction
+		if err := json.Unmarshal(response.Data, &tx); err != nil || tx.ID == "" {
+			continue
+		}
+		handle(tx)
+	}
+}
+
+// SubscribePendingTxs streams the normalized ethereum style mempool transactions
+func (c *Client) SubscribePendingTxs(ctx context.Context, coinIndex uint, handle func(types.Tx)) error {
+	return c.SubscribeNewTransactions(ctx, func(tx Transaction) {
+		handle(normalizeTx(&tx, coinIndex))
+	})
+}
+
+func websocketURL(api string) (string, error) {
+	location, err := url.Parse(api)
+	if err != nil {
+		return "", err
+	}
+	switch location.Scheme {
+	case "https":
+		location.Scheme = "wss"
+	default:
+		location.Scheme = "ws"
+	}
+	location.Path = strings.TrimSuffix(location.Path, "/") + "/websocket"
+	return location.String(), nil
+}
@@ -0,0 +1,47 @@
+package blockbook
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/client"
+	"github.com/trustwallet/golibs/types"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebsocketURL(t *testing.T) {
+	location, err := websocketURL("https://btc.example.com/")
+	assert.Nil(t, err)
+	assert.Equal(t, "wss://btc.example.com/websocket", location)
+
+	location, err = websocketURL("http://localhost:9130/blockbook")
+	assert.Nil(t, err)
+	assert.Equal(t, "ws://localhost:9130/blockbook/websocket", location)
+}
+
+func TestClient_SubscribeNewTransactions(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		var request wsRequest
+		if err := websocket.JSON.Receive(conn, &request); err != nil || request.Method != subscribeNewTransaction {
+			return
+		}
+		_ = websocket.Message.Send(conn, `{"id":"subscribeNewTransaction","data":{"subscribed":true}}`)
+		_ = websocket.Message.Send(conn, `{"id":"other","data":{"txid":"ignored"}}`)
+		_ = websocket.Message.Send(conn, `{"id":"subscribeNewTransaction","data":{"txid":"0xabc","blockHeight":-1,"fees":"21000","ethereumSpecific":{"status":-1,"nonce":3}}}`)
+	}))
+	defer server.Close()
+
+	c := Client{Request: client.InitClient(server.URL, nil)}
+	var txs []types.Tx
+	err := c.SubscribePendingTxs(context.Background(), 60, func(tx types.Tx) {
+		txs = append(txs, tx)
+	})
+
+	assert.NotNil(t, err)
+	assert.Len(t, txs, 1)
+	assert.Equal(t, "0xabc", txs[0].ID)
+	assert.Equal(t, uint64(3), txs[0].Sequence)
+	assert.Equal(t, types.StatusPending, txs[0].Status)
+}
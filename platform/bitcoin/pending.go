@@ -0,0 +1,14 @@
+package bitcoin
+
+import (
+	"context"
+
+	"github.com/trustwallet/blockatlas/platform/bitcoin/blockbook"
+	"github.com/trustwallet/golibs/types"
+)
+
+func (p *Platform) SubscribePendingTxs(ctx context.Context, handle func(types.Tx)) error {
+	return p.client.SubscribeNewTransactions(ctx, func(tx blockbook.Transaction) {
+		handle(normalizeTransaction(tx, p.CoinIndex))
+	})
+}
@@ -1,6 +1,9 @@
 package ethereum
 
-import "github.com/trustwallet/golibs/types"
+import (
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/golibs/types"
+)
 
 func (p *Platform) CurrentBlockNumber() (int64, error) {
 	return p.client.GetCurrentBlockNumber()
@@ -9,3 +12,7 @@ func (p *Platform) CurrentBlockNumber() (int64, error) {
 func (p *Platform) GetBlockByNumber(num int64) (*types.Block, error) {
 	return p.client.GetBlockByNumber(num, p.CoinIndex)
 }
+
+func (p *Platform) GetBlockWithHeader(num int64) (*types.Block, *blockatlas.BlockHeader, error) {
+	return p.client.GetBlockWithHeaderByNumber(num, p.CoinIndex)
+}
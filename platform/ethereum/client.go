@@ -1,6 +1,11 @@
 package ethereum
 
-import "github.com/trustwallet/golibs/types"
+import (
+	"context"
+
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/golibs/types"
+)
 
 type EthereumClient interface {
 	GetTransactions(address string, coinIndex uint) (types.Txs, error)
@@ -8,6 +13,8 @@ type EthereumClient interface {
 	GetTokenList(address string, coinIndex uint) ([]types.Token, error)
 	GetCurrentBlockNumber() (int64, error)
 	GetBlockByNumber(num int64, coinIndex uint) (*types.Block, error)
+	GetBlockWithHeaderByNumber(num int64, coinIndex uint) (*types.Block, *blockatlas.BlockHeader, error)
+	SubscribePendingTxs(ctx context.Context, coinIndex uint, handle func(types.Tx)) error
 }
 
 type CollectibleClient interface {
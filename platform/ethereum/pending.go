@@ -0,0 +1,11 @@
+package ethereum
+
+import (
+	"context"
+
+	"github.com/trustwallet/golibs/types"
+)
+
+func (p *Platform) SubscribePendingTxs(ctx context.Context, handle func(types.Tx)) error {
+	return p.client.SubscribePendingTxs(ctx, p.CoinIndex, handle)
+}
@@ -1,9 +1,12 @@
 package ethereum
 
 import (
+	"context"
+
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
 	"github.com/trustwallet/golibs/types"
 )
 
@@ -66,3 +69,12 @@ func (c Client) GetCurrentBlockNumber() (int64, error) {
 func (c Client) GetBlockByNumber(num int64, coinIndex uint) (*types.Block, error) {
 	return nil, nil
 }
+
+func (c Client) GetBlockWithHeaderByNumber(num int64, coinIndex uint) (*types.Block, *blockatlas.BlockHeader, error) {
+	return nil, nil, nil
+}
+
+func (c Client) SubscribePendingTxs(ctx context.Context, coinIndex uint, handle func(types.Tx)) error {
+	handle(tx)
+	return nil
+}
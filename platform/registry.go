@@ -15,6 +15,9 @@ var (
 	// TokensAPIs contain platforms with token services
 	TokensAPIs map[uint]blockatlas.TokensAPI
 
+	// XpubAPIs contain platforms deriving the addresses of extended public keys
+	XpubAPIs map[uint]blockatlas.XpubAPI
+
 	// StakeAPIs contain platforms with staking services
 	StakeAPIs map[string]blockatlas.StakeAPI
 
@@ -52,6 +55,7 @@ func Init(platformHandles []string) {
 	Platforms = make(map[string]blockatlas.Platform)
 	BlockAPIs = make(map[string]blockatlas.BlockAPI)
 	TokensAPIs = make(map[uint]blockatlas.TokensAPI)
+	XpubAPIs = make(map[uint]blockatlas.XpubAPI)
 	StakeAPIs = make(map[string]blockatlas.StakeAPI)
 
 	for _, platform := range platformList {
@@ -72,6 +76,9 @@ func Init(platformHandles []string) {
 		if tokenAPI, ok := platform.(blockatlas.TokensAPI); ok {
 			TokensAPIs[platform.Coin().ID] = tokenAPI
 		}
+		if xpubAPI, ok := platform.(blockatlas.XpubAPI); ok {
+			XpubAPIs[platform.Coin().ID] = xpubAPI
+		}
 		if stakeAPI, ok := platform.(blockatlas.StakeAPI); ok {
 			StakeAPIs[handle] = stakeAPI
 		}
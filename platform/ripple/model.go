@@ -36,7 +36,7 @@ type Payment struct {
 	TxnSignature    string          `json:"TxnSignature"`
 	Account         string          `json:"Account"`
 	Destination     string          `json:"Destination"`
-	DestinationTag  int64           `json:"DestinationTag,omitempty"`
+	DestinationTag  *int64          `json:"DestinationTag,omitempty"`
 }
 
 type Meta struct {
@@ -74,8 +74,9 @@ func NormalizeTx(srcTx *Tx) (types.Tx, bool) {
 			Decimals: coin.Coins[coin.RIPPLE].Decimals,
 		},
 	}
-	if srcTx.Payment.DestinationTag > 0 {
-		result.Memo = strconv.FormatInt(srcTx.Payment.DestinationTag, 10)
+	// 0 is a valid destination tag, only a missing one leaves the memo empty
+	if srcTx.Payment.DestinationTag != nil {
+		result.Memo = strconv.FormatInt(*srcTx.Payment.DestinationTag, 10)
 	}
 	return result, true
 }
@@ -55,7 +55,7 @@ func TestNormalizeTx(t *testing.T) {
 				Fee:    "120",
 				Date:   1565114281,
 				Block:  49163909,
-				Memo:   "",
+				Memo:   "0",
 				Status: types.StatusCompleted,
 				Meta: types.Transfer{
 					Value:    "3100",
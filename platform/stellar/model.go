@@ -13,6 +13,13 @@ const (
 	Native = "native"
 )
 
+// Memo types https://developers.stellar.org/docs/glossary/transactions/#memo
+const (
+	MemoNone   = "none"
+	MemoHash   = "hash"
+	MemoReturn = "return"
+)
+
 // PaymentsPage of payments returned by Horizon
 type PaymentsPage struct {
 	Embedded struct {
@@ -55,6 +62,7 @@ type Payment struct {
 }
 
 type Transaction struct {
-	Memo   string `json:"memo"`
-	Ledger uint64 `json:"ledger"`
+	Memo     string `json:"memo"`
+	MemoType string `json:"memo_type"`
+	Ledger   uint64 `json:"ledger"`
 }
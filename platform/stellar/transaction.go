@@ -1,6 +1,8 @@
 package stellar
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"time"
 
 	"github.com/trustwallet/golibs/coin"
@@ -65,7 +67,7 @@ func Normalize(payment *Payment, nativeCoinIndex uint) (tx types.Tx, ok bool) {
 		To:    to,
 		Fee:   FixedFee,
 		Date:  date.Unix(),
-		Memo:  payment.Transaction.Memo,
+		Memo:  normalizeMemo(payment.Transaction),
 		Block: payment.Transaction.Ledger,
 		Meta: types.Transfer{
 			Value:    types.Amount(value),
@@ -74,3 +76,19 @@ func Normalize(payment *Payment, nativeCoinIndex uint) (tx types.Tx, ok bool) {
 		},
 	}, true
 }
+
+// normalizeMemo returns text and id memos as is, and the base64 hash and return memos in hex
+func normalizeMemo(tx Transaction) string {
+	switch tx.MemoType {
+	case MemoNone:
+		return ""
+	case MemoHash, MemoReturn:
+		decoded, err := base64.StdEncoding.DecodeString(tx.Memo)
+		if err != nil {
+			return tx.Memo
+		}
+		return hex.EncodeToString(decoded)
+	default:
+		return tx.Memo
+	}
+}
@@ -89,3 +89,11 @@ func testNormalize(t *testing.T, _test *test) {
 
 	assert.Equal(t, tx, *_test.expected)
 }
+
+func TestNormalizeMemo(t *testing.T) {
+	assert.Equal(t, "testing", normalizeMemo(Transaction{Memo: "testing", MemoType: "text"}))
+	assert.Equal(t, "1234567890", normalizeMemo(Transaction{Memo: "1234567890", MemoType: "id"}))
+	assert.Equal(t, "", normalizeMemo(Transaction{MemoType: MemoNone}))
+	assert.Equal(t, "0102ff", normalizeMemo(Transaction{Memo: "AQL/", MemoType: MemoHash}))
+	assert.Equal(t, "testing", normalizeMemo(Transaction{Memo: "testing"}))
+}
@@ -1,6 +1,7 @@
 package tron
 
 import (
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
 	"github.com/trustwallet/golibs/types"
 )
 
@@ -9,9 +10,14 @@ func (p *Platform) CurrentBlockNumber() (int64, error) {
 }
 
 func (p *Platform) GetBlockByNumber(num int64) (*types.Block, error) {
+	block, _, err := p.GetBlockWithHeader(num)
+	return block, err
+}
+
+func (p *Platform) GetBlockWithHeader(num int64) (*types.Block, *blockatlas.BlockHeader, error) {
 	block, err := p.client.fetchBlockByNumber(num)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	txs := p.NormalizeBlockTxs(block.Txs)
@@ -19,7 +25,7 @@ func (p *Platform) GetBlockByNumber(num int64) (*types.Block, error) {
 	return &types.Block{
 		Number: num,
 		Txs:    txs,
-	}, nil
+	}, normalizeBlockHeader(block, num), nil
 }
 
 func (p *Platform) NormalizeBlockTxs(srcTxs []Tx) []types.Tx {
@@ -31,3 +37,15 @@ func (p *Platform) NormalizeBlockTxs(srcTxs []Tx) []types.Tx {
 	}
 	return txs
 }
+
+func normalizeBlockHeader(block Block, num int64) *blockatlas.BlockHeader {
+	if block.BlockId == "" {
+		return nil
+	}
+	return &blockatlas.BlockHeader{
+		Number:     num,
+		Hash:       block.BlockId,
+		ParentHash: block.BlockHeader.Data.ParentHash,
+		Time:       block.BlockHeader.Data.Timestamp / 1000,
+	}
+}
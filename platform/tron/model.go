@@ -21,8 +21,9 @@ type (
 	}
 
 	BlockData struct {
-		Number    int64 `json:"number"`
-		Timestamp int64 `json:"timestamp"`
+		Number     int64  `json:"number"`
+		Timestamp  int64  `json:"timestamp"`
+		ParentHash string `json:"parentHash"`
 	}
 
 	Page struct {
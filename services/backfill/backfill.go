@@ -0,0 +1,144 @@
+package backfill
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/blockatlas/services/parser"
+	"github.com/trustwallet/golibs/types"
+)
+
+type Params struct {
+	// Name identifies the run to resume
+	Name      string
+	Api       blockatlas.BlockAPI
+	From, To  int64
+	BatchSize int64
+	// MaxMessageBytes bounds the size of one published envelope, 0 means no limit
+	MaxMessageBytes int
+	Resume          bool
+	// DryRun prints the normalized transactions instead of publishing them
+	DryRun    bool
+	Output    io.Writer
+	Scheduler *parser.Scheduler
+	Publisher *internal.Publisher
+	Database  *db.Instance
+}
+
+// Run fetches the blocks in [From, To] with the parser logic and publishes their transactions with the replay
+// header. The progress is saved per batch under Name, never in the live tracker.
+func Run(params Params, ctx context.Context) error {
+	start, err := getStart(params)
+	if err != nil {
+		return err
+	}
+	if params.BatchSize <= 0 {
+		params.BatchSize = 1
+	}
+
+	for from := start; from <= params.To; from += params.BatchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		to := from + params.BatchSize - 1
+		if to > params.To {
+			to = params.To
+		}
+
+		numbers := make([]int64, 0, to-from+1)
+		for i := from; i <= to; i++ {
+			numbers = append(numbers, i)
+		}
+		blocks, failed := params.Scheduler.FetchBlocks(params.Api, numbers)
+		blocks, lastBlockNumber := parser.GetContiguousBlocks(blocks, from, to+1, nil)
+
+		var txs types.Txs
+		for _, block := range blocks {
+			txs = append(txs, block.Txs...)
+		}
+		txs = memo.FilterTransactions(txs)
+
+		if err := handle(params, txs, from, lastBlockNumber); err != nil {
+			return err
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("unable to fetch block %d of %s, resume from %d", lastBlockNumber+1, params.Name, lastBlockNumber+1)
+		}
+
+		log.WithFields(log.Fields{
+			"name":         params.Name,
+			"coin":         params.Api.Coin().Handle,
+			"from":         from,
+			"to":           to,
+			"transactions": len(txs),
+		}).Info("Backfilled blocks batch")
+	}
+	return nil
+}
+
+func getStart(params Params) (int64, error) {
+	if !params.Resume || params.DryRun {
+		return params.From, nil
+	}
+	backfill, err := params.Database.GetBackfill(params.Name)
+	if err != nil {
+		return 0, fmt.Errorf("no progress to resume for %s: %v", params.Name, err)
+	}
+	if backfill.Height >= params.From {
+		return backfill.Height + 1, nil
+	}
+	return params.From, nil
+}
+
+func handle(params Params, txs types.Txs, from, lastBlockNumber int64) error {
+	if params.DryRun {
+		encoder := json.NewEncoder(params.Output)
+		for _, tx := range txs {
+			if err := encoder.Encode(&tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	groups, keys := internal.GroupByRoutingKey(txs)
+	for _, key := range keys {
+		batch := internal.Batch{
+			ID:        fmt.Sprintf("backfill:%s:%d-%d:%s", params.Name, from, lastBlockNumber, key),
+			Coin:      params.Api.Coin().ID,
+			FromBlock: from,
+			ToBlock:   lastBlockNumber,
+		}
+		envelopes, err := batch.Envelopes(groups[key], params.MaxMessageBytes)
+		if err != nil {
+			return err
+		}
+		for _, envelope := range envelopes {
+			body, err := json.Marshal(envelope)
+			if err != nil {
+				return err
+			}
+			err = params.Publisher.PublishWithRoutingKey(internal.RawTransactionsExchange, key, amqp.Table{internal.ReplayHeader: true}, body)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return params.Database.SaveBackfill(models.Backfill{
+		Name:   params.Name,
+		Coin:   params.Api.Coin().Handle,
+		From:   params.From,
+		To:     params.To,
+		Height: lastBlockNumber,
+	})
+}
@@ -0,0 +1,60 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/services/parser"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+type platform struct{}
+
+func (p platform) Coin() coin.Coin {
+	return coin.Ethereum()
+}
+
+func (p platform) CurrentBlockNumber() (int64, error) {
+	return 100, nil
+}
+
+func (p platform) GetBlockByNumber(num int64) (*types.Block, error) {
+	return &types.Block{Number: num, Txs: []types.Tx{{
+		ID:    "tx",
+		Coin:  coin.ETHEREUM,
+		Fee:   "1",
+		Block: uint64(num),
+		Meta:  types.Transfer{Value: "1", Symbol: "ETH", Decimals: 18},
+	}}}, nil
+}
+
+func TestRun_DryRun(t *testing.T) {
+	var output bytes.Buffer
+	params := Params{
+		Name:      "test",
+		Api:       platform{},
+		From:      10,
+		To:        14,
+		BatchSize: 2,
+		DryRun:    true,
+		Output:    &output,
+		Scheduler: parser.NewScheduler(2, 0),
+	}
+
+	assert.Nil(t, Run(params, context.Background()))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Contains(t, lines[0], `"block":10`)
+	assert.Contains(t, lines[4], `"block":14`)
+}
+
+func TestRun_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	params := Params{Api: platform{}, From: 1, To: 2, DryRun: true, Scheduler: parser.NewScheduler(1, 0)}
+	assert.Equal(t, context.Canceled, Run(params, ctx))
+}
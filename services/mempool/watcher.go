@@ -0,0 +1,183 @@
+package mempool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/blockatlas/services/parser"
+	"github.com/trustwallet/golibs/network/mq"
+	"github.com/trustwallet/golibs/types"
+)
+
+const (
+	defaultFlushInterval     = time.Second
+	defaultReconnectInterval = time.Second * 10
+)
+
+type Params struct {
+	Api               blockatlas.PendingTxAPI
+	Queue             mq.Queue
+	BatchSize         int
+	FlushInterval     time.Duration
+	ReconnectInterval time.Duration
+	MaxMessageBytes   int
+	Lease             *parser.Lease
+	Txs               chan types.Tx
+}
+
+// NewParams creates the params of a watcher and its collector, sharing the channel of the streamed transactions
+func NewParams(params Params) Params {
+	if params.FlushInterval <= 0 {
+		params.FlushInterval = defaultFlushInterval
+	}
+	if params.ReconnectInterval <= 0 {
+		params.ReconnectInterval = defaultReconnectInterval
+	}
+	params.Txs = make(chan types.Tx, params.BatchSize)
+	return params
+}
+
+// RunWatcher streams the mempool of the coin to the collector. When the lease is set, only its holder streams,
+// so the parser replicas don't publish the same transaction.
+func RunWatcher(params Params, ctx context.Context) {
+	coin := params.Api.Coin().Handle
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info(fmt.Sprintf("Mempool watcher of %s stopped", coin))
+			return
+		default:
+		}
+
+		if params.isLeader() {
+			if err := watch(params, ctx, params.Txs); err != nil {
+				log.WithFields(log.Fields{
+					"operation": "run SubscribePendingTxs",
+					"coin":      coin,
+					"tags":      raven.Tags{{Key: "coin", Value: coin}},
+				}).Error(err)
+			}
+		}
+		time.Sleep(params.ReconnectInterval)
+	}
+}
+
+func (p Params) isLeader() bool {
+	return p.Lease == nil || p.Lease.Held()
+}
+
+// watch streams until the context is done, the stream fails or the lease is lost
+func watch(params Params, ctx context.Context, txs chan<- types.Tx) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if params.Lease != nil {
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-streamCtx.Done():
+					return
+				case <-ticker.C:
+					if !params.Lease.Held() {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	log.WithFields(log.Fields{"coin": params.Api.Coin().Handle}).Info("Watching mempool")
+	return params.Api.SubscribePendingTxs(streamCtx, func(tx types.Tx) {
+		tx.Status = types.StatusPending
+		select {
+		case txs <- tx:
+		case <-streamCtx.Done():
+		}
+	})
+}
+
+// RunCollector publishes the transactions streamed by the watcher as pending to the queue, in batches
+func RunCollector(params Params, ctx context.Context) {
+	collect(ctx, params.Txs, params.BatchSize, params.FlushInterval, func(batch types.Txs) {
+		publish(params, batch)
+	})
+	log.Info(fmt.Sprintf("Mempool collector of %s stopped", params.Api.Coin().Handle))
+}
+
+// collect batches the transactions and flushes a batch once it is full or the interval elapsed.
+// Once ctx is done, it flushes the transactions left before returning.
+func collect(ctx context.Context, txs <-chan types.Tx, batchSize int, interval time.Duration, flush func(types.Txs)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make(types.Txs, 0, batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case tx := <-txs:
+					batch = append(batch, tx)
+					continue
+				default:
+				}
+				break
+			}
+			if len(batch) > 0 {
+				flush(batch)
+			}
+			return
+		case tx := <-txs:
+			batch = append(batch, tx)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		flush(batch)
+		batch = make(types.Txs, 0, batchSize)
+	}
+}
+
+func publish(params Params, txs types.Txs) {
+	txs = memo.FilterTransactions(txs)
+	if len(txs) == 0 {
+		return
+	}
+	batch := internal.Batch{
+		ID:   fmt.Sprintf("pending:%s:%d", params.Api.Coin().Handle, time.Now().UnixNano()),
+		Coin: params.Api.Coin().ID,
+	}
+	envelopes, err := batch.Envelopes(txs, params.MaxMessageBytes)
+	if err != nil {
+		log.WithFields(log.Fields{"operation": "publish marshal", "coin": params.Api.Coin().Handle}).Error(err)
+		return
+	}
+	for _, envelope := range envelopes {
+		body, err := json.Marshal(envelope)
+		if err != nil {
+			log.WithFields(log.Fields{"operation": "publish marshal", "coin": params.Api.Coin().Handle}).Error(err)
+			return
+		}
+		if err := params.Queue.Publish(body); err != nil {
+			log.WithFields(log.Fields{"coin": params.Api.Coin().Handle, "transactions": envelope.Count, "error": err}).Error("Publish Error")
+			return
+		}
+		metrics.AddPublished(params.Api.Coin().Handle, metrics.SourceMempool, 1, envelope.Count)
+	}
+	log.WithFields(log.Fields{"coin": params.Api.Coin().Handle, "transactions": len(txs)}).Info("Published pending transactions")
+}
@@ -0,0 +1,62 @@
+package mempool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestCollect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		batches []types.Txs
+		txs     = make(chan types.Tx)
+	)
+	go collect(ctx, txs, 2, time.Millisecond*50, func(batch types.Txs) {
+		mu.Lock()
+		batches = append(batches, batch)
+		mu.Unlock()
+	})
+
+	txs <- types.Tx{ID: "1"}
+	txs <- types.Tx{ID: "2"}
+	txs <- types.Tx{ID: "3"}
+	time.Sleep(time.Millisecond * 200)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, batches, 2)
+	assert.Equal(t, types.Txs{{ID: "1"}, {ID: "2"}}, batches[0])
+	assert.Equal(t, types.Txs{{ID: "3"}}, batches[1])
+}
+
+func TestCollect_FlushOnDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		batches []types.Txs
+		txs     = make(chan types.Tx, 2)
+		done    = make(chan struct{})
+	)
+	go func() {
+		collect(ctx, txs, 10, time.Hour, func(batch types.Txs) {
+			batches = append(batches, batch)
+		})
+		close(done)
+	}()
+
+	txs <- types.Tx{ID: "1"}
+	txs <- types.Tx{ID: "2"}
+	cancel()
+	<-done
+
+	assert.Len(t, batches, 1)
+	assert.Equal(t, types.Txs{{ID: "1"}, {ID: "2"}}, batches[0])
+}
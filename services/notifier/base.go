@@ -7,6 +7,13 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/blockatlas/services/subscriber"
+	"github.com/trustwallet/golibs/coin"
 	"github.com/trustwallet/golibs/types"
 )
 
@@ -15,52 +22,189 @@ const (
 )
 
 func RunNotifier(database *db.Instance, delivery amqp.Delivery) error {
-	transactions, err := GetTransactionsFromDelivery(delivery, Notifier)
+	transactions, confirmation, err := getTransactionsFromDelivery(delivery, Notifier)
 	if err != nil {
 		log.WithFields(log.Fields{"service": Notifier, "body": string(delivery.Body), "error": err}).Error("Unable to unmarshal MQ Message")
+		return internal.Permanent(err)
+	}
+
+	if len(transactions) == 0 {
 		return nil
 	}
 
 	allAddresses := make([]string, 0)
 	for _, tx := range transactions {
-		allAddresses = append(allAddresses, tx.GetAddresses()...)
+		allAddresses = append(allAddresses, utxoAddresses(tx)...)
 	}
 
-	addresses := ToUniqueAddresses(allAddresses)
-	for i := range addresses {
-		addresses[i] = strconv.Itoa(int(transactions[0].Coin)) + "_" + addresses[i]
+	coinID := strconv.Itoa(int(transactions[0].Coin))
+	for i := range allAddresses {
+		allAddresses[i] = canonical.AddressID(coinID, allAddresses[i])
 	}
+	addresses := ToUniqueAddresses(allAddresses)
 
-	if len(transactions) == 0 {
-		return nil
+	coin := coinHandle(transactions[0].Coin)
+	if prefilter != nil {
+		addresses = prefilter.Filter(addresses)
+		if len(addresses) == 0 {
+			return nil
+		}
 	}
 	subscriptions, err := database.GetSubscriptions(addresses)
 	if err != nil {
-		return nil
+		return err
+	}
+	if prefilter != nil {
+		metrics.AddPrefilterLookups(coin, len(addresses), len(subscriptions))
+	}
+
+	subscribed := make([]string, 0, len(subscriptions))
+	for _, sub := range subscriptions {
+		subscribed = append(subscribed, sub.Address)
+	}
+	xpubAddresses, err := database.GetXpubAddresses(subscribed)
+	if err != nil {
+		log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to get xpub addresses")
+		return err
+	}
+	byXpub, derived := groupXpubAddresses(xpubAddresses)
+	subscriptionWallets, xpubWallets, err := getWallets(database, subscriptions, byXpub)
+	if err != nil {
+		log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to get wallets")
+		return err
 	}
 
 	notifications := make([]types.TransactionNotification, 0)
+	keys := make([]string, 0)
+	wallets := make(map[string][]string)
 	for _, sub := range subscriptions {
-		ua, _, ok := UnprefixedAddress(sub.Address)
+		if xpubOnly(sub, derived, subscriptionWallets) {
+			continue
+		}
+		ua, coinID, ok := UnprefixedAddress(sub.Address)
 		if !ok {
 			continue
 		}
-		notificationsForAddress := buildNotificationsByAddress(ua, transactions)
+		notificationsForAddress := filterMemo(buildNotificationsByAddress(ua, transactions), coinID, sub.Memo)
+		notificationsForAddress = filterNotifications(notificationsForAddress, sub.Rules)
+		for _, notification := range notificationsForAddress {
+			key := notificationKey(memo.Join(ua, sub.Memo), notification)
+			keys = append(keys, key)
+			wallets[key] = subscriptionWallets[sub.ID]
+		}
 		notifications = append(notifications, notificationsForAddress...)
 	}
+	for xpubID, addresses := range byXpub {
+		walletAddresses := make([]string, 0, len(addresses))
+		for _, address := range addresses {
+			if ua, _, ok := UnprefixedAddress(address.Address); ok {
+				walletAddresses = append(walletAddresses, ua)
+			}
+		}
+		for _, notification := range buildWalletNotifications(walletAddresses, transactions) {
+			key := notificationKey(addresses[0].Xpub.Key, notification)
+			keys = append(keys, key)
+			wallets[key] = xpubWallets[xpubID]
+			notifications = append(notifications, notification)
+		}
+	}
+	refreshXpubs(database, xpubAddresses)
+
+	if deduplicator != nil && len(notifications) > 0 {
+		notifications, keys, err = deduplicate(coin, notifications, keys, confirmation)
+		if err != nil {
+			log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to deduplicate notifications")
+			return err
+		}
+	}
 
 	if len(notifications) == 0 {
 		return nil
 	}
 
-	err = publishNotifications(notifications)
+	err = publishNotifications(withConfirmation(withWallets(notifications, keys, wallets), keys, confirmation))
 	if err != nil {
 		log.WithFields(log.Fields{"service": Notifier}).Error(err)
+		if deduplicator != nil {
+			if err := deduplicator.Release(confirmationKeys(keys, confirmation)); err != nil {
+				log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to release notification keys")
+			}
+		}
+		return err
 	}
+	metrics.AddNotifications(coin, len(notifications))
 
 	return nil
 }
 
+// deduplicate keeps the notifications not published yet at the confirmation, along with their keys
+func deduplicate(coin string, notifications []types.TransactionNotification, keys []string, confirmation *internal.Confirmation) ([]types.TransactionNotification, []string, error) {
+	claimKeys := confirmationKeys(keys, confirmation)
+	claimed, err := deduplicator.Claim(coin, claimKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	publish := make(map[string]bool, len(claimed))
+	for _, key := range claimed {
+		publish[key] = true
+	}
+
+	resultNotifications := make([]types.TransactionNotification, 0, len(claimed))
+	resultKeys := make([]string, 0, len(claimed))
+	for i, key := range claimKeys {
+		if !publish[key] {
+			continue
+		}
+		delete(publish, key)
+		resultNotifications = append(resultNotifications, notifications[i])
+		resultKeys = append(resultKeys, keys[i])
+	}
+	return resultNotifications, resultKeys, nil
+}
+
+// getWallets returns the wallets of the subscriptions by subscription id, and those of the xpubs by xpub id
+func getWallets(database *db.Instance, subscriptions []models.Subscription, byXpub map[uint][]models.XpubAddress) (map[uint][]string, map[uint][]string, error) {
+	subscriptionIDs := make([]uint, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subscriptionIDs = append(subscriptionIDs, subscription.ID)
+	}
+	subscriptionWallets, err := database.GetSubscriptionWallets(subscriptionIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	xpubIDs := make([]uint, 0, len(byXpub))
+	for xpubID := range byXpub {
+		xpubIDs = append(xpubIDs, xpubID)
+	}
+	xpubWallets, err := database.GetXpubWallets(xpubIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return subscriptionWallets, xpubWallets, nil
+}
+
+// refreshXpubs flags the derived addresses used, the xpubs which had one of them unused extend their derived set
+func refreshXpubs(database *db.Instance, xpubAddresses []models.XpubAddress) {
+	if len(xpubAddresses) == 0 {
+		return
+	}
+	addresses := make([]string, 0, len(xpubAddresses))
+	for _, address := range xpubAddresses {
+		addresses = append(addresses, address.Address)
+	}
+	xpubs, err := database.MarkXpubAddressesUsed(addresses)
+	if err != nil {
+		log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to mark xpub addresses used")
+		return
+	}
+	if len(xpubs) == 0 {
+		return
+	}
+	if err := subscriber.RefreshXpubs(xpubs); err != nil {
+		log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to refresh xpubs")
+	}
+}
+
 func UnprefixedAddress(address string) (string, uint, bool) {
 	result := strings.Split(address, "_")
 	if len(result) != 2 {
@@ -76,3 +220,10 @@ func UnprefixedAddress(address string) (string, uint, bool) {
 	}
 	return addr, uint(id), true
 }
+
+func coinHandle(id uint) string {
+	if c, ok := coin.Coins[id]; ok {
+		return c.Handle
+	}
+	return strconv.Itoa(int(id))
+}
@@ -0,0 +1,180 @@
+package notifier
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/golibs/types"
+)
+
+const (
+	defaultDedupTTL  = time.Hour * 24
+	defaultDedupSize = 100000
+)
+
+// deduplicator is used by RunNotifier once set up, notifications are published as is without it
+var deduplicator *Deduplicator
+
+// Deduplicator suppresses notifications already published within the ttl, so a batch redelivered by RabbitMQ
+// doesn't notify users twice. Recently published keys are kept in a bounded memory layer in front of
+// the database, which is shared by the consumers.
+type Deduplicator struct {
+	database *db.Instance
+	ttl      time.Duration
+	size     int
+
+	mu     sync.Mutex
+	recent map[string]*list.Element
+	order  *list.List
+}
+
+type dedupEntry struct {
+	key string
+	at  time.Time
+}
+
+func NewDeduplicator(database *db.Instance, ttl time.Duration, size int) *Deduplicator {
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+	if size <= 0 {
+		size = defaultDedupSize
+	}
+	return &Deduplicator{
+		database: database,
+		ttl:      ttl,
+		size:     size,
+		recent:   make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// SetupDeduplicator makes RunNotifier suppress the notifications already published
+func SetupDeduplicator(d *Deduplicator) {
+	deduplicator = d
+}
+
+// notificationKey identifies a notification by coin, transaction, subscribed address, direction and status,
+// so a pending transaction is notified again once it completes
+func notificationKey(address string, notification types.TransactionNotification) string {
+	tx := notification.Result
+	return fmt.Sprintf("%d:%s:%s:%s:%s", tx.Coin, tx.ID, address, tx.Direction, tx.Status)
+}
+
+// notificationID identifies the notifications of a key across confirmations, without exposing the subscribed
+// address or xpub. The status ending the key is left out, a transaction reverted by a reorg keeps the id it was
+// seen and confirmed with.
+func notificationID(key string) string {
+	if i := strings.LastIndex(key, ":"); i >= 0 {
+		key = key[:i]
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:16])
+}
+
+// confirmationKeys extends the keys with the confirmation of the notifications, so a transaction is notified once
+// per confirmation threshold
+func confirmationKeys(keys []string, confirmation *internal.Confirmation) []string {
+	if confirmation == nil {
+		return keys
+	}
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, fmt.Sprintf("%s:%s:%d", key, confirmation.Status, confirmation.Confirmations))
+	}
+	return result
+}
+
+// Claim returns the keys to publish, the others were already published within the ttl
+func (d *Deduplicator) Claim(coin string, keys []string) ([]string, error) {
+	now := time.Now()
+	candidates := make([]string, 0, len(keys))
+	d.mu.Lock()
+	for _, key := range keys {
+		if d.seen(key, now) {
+			continue
+		}
+		candidates = append(candidates, key)
+	}
+	d.mu.Unlock()
+	metrics.AddDuplicates(coin, metrics.LayerMemory, len(keys)-len(candidates))
+
+	claimed, err := d.database.ClaimNotifications(candidates, d.ttl)
+	if err != nil {
+		return nil, err
+	}
+	metrics.AddDuplicates(coin, metrics.LayerDatabase, len(candidates)-len(claimed))
+
+	d.mu.Lock()
+	for _, key := range candidates {
+		d.remember(key, now)
+	}
+	d.mu.Unlock()
+	return claimed, nil
+}
+
+// Release forgets the keys of notifications which could not be published, so a redelivery publishes them
+func (d *Deduplicator) Release(keys []string) error {
+	d.mu.Lock()
+	for _, key := range keys {
+		if element, ok := d.recent[key]; ok {
+			d.order.Remove(element)
+			delete(d.recent, key)
+		}
+	}
+	d.mu.Unlock()
+	return d.database.ReleaseNotifications(keys)
+}
+
+// RunCleanup deletes the expired keys from the database until ctx is done
+func (d *Deduplicator) RunCleanup(ctx context.Context) {
+	ticker := time.NewTicker(d.ttl / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.database.DeleteExpiredNotifications(time.Now().Add(-d.ttl)); err != nil {
+				log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to delete expired notification keys")
+			}
+		}
+	}
+}
+
+func (d *Deduplicator) seen(key string, now time.Time) bool {
+	element, ok := d.recent[key]
+	if !ok {
+		return false
+	}
+	if now.Sub(element.Value.(dedupEntry).at) > d.ttl {
+		d.order.Remove(element)
+		delete(d.recent, key)
+		return false
+	}
+	return true
+}
+
+func (d *Deduplicator) remember(key string, now time.Time) {
+	if element, ok := d.recent[key]; ok {
+		element.Value = dedupEntry{key: key, at: now}
+		d.order.MoveToFront(element)
+		return
+	}
+	d.recent[key] = d.order.PushFront(dedupEntry{key: key, at: now})
+	for d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.recent, oldest.Value.(dedupEntry).key)
+	}
+}
@@ -0,0 +1,61 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestNotificationKey(t *testing.T) {
+	notification := types.TransactionNotification{Result: types.Tx{
+		ID:        "0x1",
+		Coin:      60,
+		Direction: types.DirectionIncoming,
+		Status:    types.StatusPending,
+	}}
+	assert.Equal(t, "60:0x1:0xa:incoming:pending", notificationKey("0xa", notification))
+
+	notification.Result.Status = types.StatusCompleted
+	assert.Equal(t, "60:0x1:0xa:incoming:completed", notificationKey("0xa", notification))
+}
+
+func TestNotificationID(t *testing.T) {
+	notification := types.TransactionNotification{Result: types.Tx{
+		ID:        "0x1",
+		Coin:      60,
+		Direction: types.DirectionIncoming,
+		Status:    types.StatusCompleted,
+	}}
+	id := notificationID(notificationKey("0xa", notification))
+
+	notification.Result.Status = "reverted"
+	assert.Equal(t, id, notificationID(notificationKey("0xa", notification)))
+	assert.NotEqual(t, id, notificationID(notificationKey("0xb", notification)))
+	assert.NotEqual(t, id, notificationID(notificationKey("0xa:1", notification)))
+}
+
+func TestDeduplicator_Memory(t *testing.T) {
+	d := NewDeduplicator(nil, time.Minute, 2)
+	now := time.Now()
+	d.remember("a", now)
+	d.remember("b", now)
+
+	claimed, err := d.Claim("ethereum", []string{"a", "b"})
+	assert.Nil(t, err)
+	assert.Empty(t, claimed)
+
+	d.remember("c", now)
+	assert.False(t, d.seen("a", now))
+	assert.True(t, d.seen("b", now))
+	assert.False(t, d.seen("b", now.Add(time.Minute*2)))
+	assert.True(t, d.seen("c", now))
+}
+
+func TestConfirmationKeys(t *testing.T) {
+	keys := []string{"0:1:a::completed"}
+	assert.Equal(t, keys, confirmationKeys(keys, nil))
+	assert.Equal(t, []string{"0:1:a::completed:confirmed:3"}, confirmationKeys(keys, &internal.Confirmation{Status: internal.ConfirmationConfirmed, Confirmations: 3}))
+}
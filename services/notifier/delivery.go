@@ -2,6 +2,7 @@ package notifier
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/trustwallet/blockatlas/internal"
 	"github.com/trustwallet/golibs/types"
@@ -10,19 +11,68 @@ import (
 	"github.com/streadway/amqp"
 )
 
+// GetTransactionsFromDelivery reads the transactions of a raw transactions envelope. Plain arrays published
+// before envelopes are still accepted.
 func GetTransactionsFromDelivery(delivery amqp.Delivery, service string) (types.Txs, error) {
-	var transactions types.Txs
+	transactions, _, err := getTransactionsFromDelivery(delivery, service)
+	return transactions, err
+}
 
-	if err := json.Unmarshal(delivery.Body, &transactions); err != nil {
-		return nil, err
+// getTransactionsFromDelivery is GetTransactionsFromDelivery along with the confirmation of the transactions,
+// nil unless their coin is notified before its final confirmation
+func getTransactionsFromDelivery(delivery amqp.Delivery, service string) (types.Txs, *internal.Confirmation, error) {
+	transactions, envelope, err := internal.DecodeTransactions(delivery.Body)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	log.WithFields(log.Fields{"service": service, "notifications": len(transactions)}).Info("Consumed")
+	fields := log.Fields{"service": service, "notifications": len(transactions), "replay": internal.IsReplay(delivery)}
+	if envelope != nil {
+		fields["batch_id"] = envelope.BatchID
+		fields["chunk"] = fmt.Sprintf("%d/%d", envelope.Chunk+1, envelope.Chunks)
+	}
+	log.WithFields(fields).Info("Consumed")
 
-	return transactions, nil
+	if envelope == nil {
+		return transactions, nil, nil
+	}
+	return transactions, envelope.Confirmation, nil
+}
+
+// Notification is published to TxNotifications, along with the wallets of the subscription it matched. The
+// transactions of coins notified before their final confirmation are notified again at each threshold, with
+// the same ID and their status and confirmations.
+type Notification struct {
+	types.TransactionNotification
+	ID            string   `json:"id,omitempty"`
+	Status        string   `json:"status,omitempty"`
+	Confirmations int64    `json:"confirmations,omitempty"`
+	Wallets       []string `json:"wallets,omitempty"`
+}
+
+// withWallets pairs the notifications with the wallets of their keys
+func withWallets(notifications []types.TransactionNotification, keys []string, wallets map[string][]string) []Notification {
+	result := make([]Notification, 0, len(notifications))
+	for i, notification := range notifications {
+		result = append(result, Notification{TransactionNotification: notification, Wallets: wallets[keys[i]]})
+	}
+	return result
+}
+
+// withConfirmation sets the confirmation of the notifications, along with an ID derived from their keys
+func withConfirmation(notifications []Notification, keys []string, confirmation *internal.Confirmation) []Notification {
+	if confirmation == nil {
+		return notifications
+	}
+	for i := range notifications {
+		notifications[i].ID = notificationID(keys[i])
+		notifications[i].Status = confirmation.Status
+		notifications[i].Confirmations = confirmation.Confirmations
+	}
+	return notifications
 }
 
-func publishNotifications(notifications []types.TransactionNotification) error {
+func publishNotifications(notifications []Notification) error {
 	raw, err := json.Marshal(notifications)
 	if err != nil {
 		return err
@@ -0,0 +1,65 @@
+package notifier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestWithWallets(t *testing.T) {
+	notifications := []types.TransactionNotification{
+		{Action: types.TxTransfer, Result: types.Tx{ID: "1", Fee: "1", Type: types.TxTransfer, Meta: types.Transfer{Value: "1"}}},
+		{Action: types.TxTransfer, Result: types.Tx{ID: "2", Fee: "1", Type: types.TxTransfer, Meta: types.Transfer{Value: "2"}}},
+	}
+	result := withWallets(notifications, []string{"a", "b"}, map[string][]string{"a": {"w1", "w2"}})
+	assert.Len(t, result, 2)
+	assert.Equal(t, []string{"w1", "w2"}, result[0].Wallets)
+	assert.Nil(t, result[1].Wallets)
+
+	raw, err := json.Marshal(result)
+	assert.Nil(t, err)
+	var decoded []map[string]interface{}
+	assert.Nil(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, []interface{}{"w1", "w2"}, decoded[0]["wallets"])
+	assert.Equal(t, "transfer", decoded[0]["action"])
+	assert.NotContains(t, decoded[1], "wallets")
+
+	// consumers of types.TransactionNotification are unaffected
+	var plain []types.TransactionNotification
+	assert.Nil(t, json.Unmarshal(raw, &plain))
+	assert.Len(t, plain, 2)
+	assert.Equal(t, "1", plain[0].Result.ID)
+}
+
+func TestWithConfirmation(t *testing.T) {
+	notifications := []Notification{
+		{TransactionNotification: types.TransactionNotification{Result: types.Tx{ID: "1"}}},
+		{TransactionNotification: types.TransactionNotification{Result: types.Tx{ID: "2"}}},
+	}
+	keys := []string{"0:1:a::completed", "0:2:a::completed"}
+
+	assert.Equal(t, notifications, withConfirmation(notifications, keys, nil))
+
+	result := withConfirmation(notifications, keys, &internal.Confirmation{Status: internal.ConfirmationConfirmed, Confirmations: 3})
+	assert.Equal(t, internal.ConfirmationConfirmed, result[0].Status)
+	assert.Equal(t, int64(3), result[0].Confirmations)
+	assert.NotEmpty(t, result[0].ID)
+	assert.NotEqual(t, result[0].ID, result[1].ID)
+
+	// the same notification keeps its id from the block it was seen in to its final threshold or its revert
+	seen := []Notification{{TransactionNotification: types.TransactionNotification{Result: types.Tx{ID: "1"}}}}
+	seen = withConfirmation(seen, keys[:1], &internal.Confirmation{Status: internal.ConfirmationSeen, Confirmations: 1})
+	assert.Equal(t, result[0].ID, seen[0].ID)
+
+	again := []Notification{{TransactionNotification: types.TransactionNotification{Result: types.Tx{ID: "1"}}}}
+	again = withConfirmation(again, keys[:1], &internal.Confirmation{Status: internal.ConfirmationFinal, Confirmations: 6})
+	assert.Equal(t, result[0].ID, again[0].ID)
+
+	reverted := []Notification{{TransactionNotification: types.TransactionNotification{Result: types.Tx{ID: "1", Status: "reverted"}}}}
+	reverted = withConfirmation(reverted, []string{"0:1:a::reverted"}, &internal.Confirmation{Status: internal.ConfirmationReverted})
+	assert.Equal(t, internal.ConfirmationReverted, reverted[0].Status)
+	assert.Equal(t, result[0].ID, reverted[0].ID)
+}
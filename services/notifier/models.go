@@ -1,14 +1,20 @@
 package notifier
 
-import "github.com/trustwallet/golibs/types"
+import (
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/golibs/types"
+)
 
+// buildNotificationsByAddress builds the notifications of the address, matched by canonical form. The direction
+// and value are computed with the address as written in each transaction.
 func buildNotificationsByAddress(address string, txs types.Txs) []types.TransactionNotification {
 	transactionsByAddress := toUniqueTransactions(findTransactionsByAddress(txs, address))
 
 	result := make([]types.TransactionNotification, 0, len(transactionsByAddress))
 	for _, tx := range transactionsByAddress {
-		tx.Direction = tx.GetTransactionDirection(address)
-		tx.InferUtxoValue(address, tx.Coin)
+		txAddress, _ := findAddress(tx, address)
+		tx.Direction = tx.GetTransactionDirection(txAddress)
+		tx.InferUtxoValue(txAddress, tx.Coin)
 		result = append(result, types.TransactionNotification{Action: tx.Type, Result: tx})
 	}
 
@@ -51,12 +57,19 @@ func findTransactionsByAddress(txs types.Txs, address string) types.Txs {
 }
 
 func containsAddress(tx types.Tx, address string) bool {
+	_, ok := findAddress(tx, address)
+	return ok
+}
+
+// findAddress returns the address of the transaction with the same canonical form as the address
+func findAddress(tx types.Tx, address string) (string, bool) {
+	address = canonical.Address(tx.Coin, address)
 	allAddresses := tx.GetAddresses()
 	txAddresses := ToUniqueAddresses(allAddresses)
 	for _, a := range txAddresses {
-		if a == address {
-			return true
+		if canonical.Address(tx.Coin, a) == address {
+			return a, true
 		}
 	}
-	return false
+	return "", false
 }
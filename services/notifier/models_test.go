@@ -109,6 +109,7 @@ func Test_containsAddress(t *testing.T) {
 	assert.False(t, containsAddress(tokenTransfer, "0xdd974D5C2e2928deA5F71b9825b8b646686BD200"))
 	assert.True(t, containsAddress(tokenTransfer, "0x38d45371993eEc84f38FEDf93C646aA2D2267CEA"))
 	assert.False(t, containsAddress(tokenTransfer, "0xdd974D5C2e2928deA5F71b9825b8b646686BD200"))
+	assert.True(t, containsAddress(tokenTransfer, "0x08777cb1e80f45642752662b04886df2d271e049"))
 
 	assert.True(t, containsAddress(transfer, "tbnb1fhr04azuhcj0dulm7ka40y0cqjlafwae9k9gk2"))
 	assert.False(t, containsAddress(transfer, "1681EE543FB4B5A628EF21D746E031F018E226D127044A4F9BA5EE2542A44556"))
@@ -144,3 +145,10 @@ func Test_buildNotificationsByAddress(t *testing.T) {
 	nativeTokenTransfer.Direction = types.DirectionOutgoing
 	assert.Equal(t, nativeTokenTransfer, notifications[0].Result)
 }
+
+func Test_buildNotificationsByAddress_Canonical(t *testing.T) {
+	notifications := buildNotificationsByAddress("0x38d45371993eec84f38fedf93c646aa2d2267cea", types.Txs{tokenTransfer})
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, types.DirectionIncoming, notifications[0].Result.Direction)
+	assert.Equal(t, "0x38d45371993eEc84f38FEDf93C646aA2D2267CEA", notifications[0].Result.Meta.(types.TokenTransfer).To)
+}
@@ -0,0 +1,220 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/golibs/types"
+)
+
+const (
+	defaultFalsePositiveRate = 0.01
+	defaultRebuildInterval   = time.Hour
+	minPrefilterCapacity     = 1 << 16
+	warmPageSize             = 10000
+)
+
+// prefilter is used by RunNotifier once set up, every address is queried without it
+var prefilter *Prefilter
+
+// Prefilter is a bloom filter of the subscribed address ids, so the notifier only queries the likely hits.
+// It is warmed from the database and follows the subscription events. A bloom filter can't forget,
+// removed subscriptions stay false positives until the next rebuild.
+type Prefilter struct {
+	database          *db.Instance
+	falsePositiveRate float64
+
+	mu    sync.RWMutex
+	bloom *bloom
+}
+
+func NewPrefilter(database *db.Instance, falsePositiveRate float64) *Prefilter {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = defaultFalsePositiveRate
+	}
+	return &Prefilter{database: database, falsePositiveRate: falsePositiveRate}
+}
+
+// SetupPrefilter makes RunNotifier query only the addresses the prefilter may contain
+func SetupPrefilter(p *Prefilter) {
+	prefilter = p
+}
+
+// Rebuild loads every subscription into a new filter sized for twice their number, then swaps it in
+func (p *Prefilter) Rebuild() error {
+	addressIDs := make([]string, 0)
+	var afterID uint
+	for {
+		page, err := p.database.GetSubscriptionsPage(afterID, warmPageSize)
+		if err != nil {
+			return err
+		}
+		for _, subscription := range page {
+			addressIDs = append(addressIDs, subscription.Address)
+		}
+		if len(page) < warmPageSize {
+			break
+		}
+		afterID = page[len(page)-1].ID
+	}
+
+	capacity := len(addressIDs) * 2
+	if capacity < minPrefilterCapacity {
+		capacity = minPrefilterCapacity
+	}
+	filter := newBloom(capacity, p.falsePositiveRate)
+	for _, addressID := range addressIDs {
+		filter.add(addressID)
+	}
+
+	p.mu.Lock()
+	p.bloom = filter
+	p.mu.Unlock()
+	p.observe()
+
+	log.WithFields(log.Fields{"service": Notifier, "subscriptions": len(addressIDs), "bytes": filter.sizeBytes()}).Info("Prefilter built")
+	return nil
+}
+
+// Run applies the subscription events and rebuilds the filter every interval until ctx is done
+func (p *Prefilter) Run(ctx context.Context, events <-chan amqp.Delivery, rebuildInterval time.Duration) {
+	if rebuildInterval <= 0 {
+		rebuildInterval = defaultRebuildInterval
+	}
+	ticker := time.NewTicker(rebuildInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				log.WithFields(log.Fields{"service": Notifier}).Error("Subscriptions updates closed, prefilter only follows the rebuilds")
+				events = nil
+				continue
+			}
+			p.apply(event.Body)
+		case <-ticker.C:
+			if err := p.Rebuild(); err != nil {
+				log.WithFields(log.Fields{"service": Notifier, "error": err}).Error("Unable to rebuild prefilter")
+			}
+		}
+	}
+}
+
+// Filter returns the address ids which may be subscribed
+func (p *Prefilter) Filter(addressIDs []string) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.bloom == nil {
+		return addressIDs
+	}
+	result := make([]string, 0)
+	for _, addressID := range addressIDs {
+		if p.bloom.mayContain(addressID) {
+			result = append(result, addressID)
+		}
+	}
+	return result
+}
+
+func (p *Prefilter) Add(addressIDs []string) {
+	p.mu.Lock()
+	if p.bloom != nil {
+		for _, addressID := range addressIDs {
+			p.bloom.add(addressID)
+		}
+	}
+	p.mu.Unlock()
+	p.observe()
+}
+
+func (p *Prefilter) apply(body []byte) {
+	var event types.SubscriptionEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		log.WithFields(log.Fields{"service": Notifier, "body": string(body), "error": err}).Error("Unable to unmarshal subscriptions update")
+		return
+	}
+	if event.Operation != types.AddSubscription {
+		return
+	}
+	subscriptions := canonical.Subscriptions(event.ParseSubscriptions(event.Subscriptions))
+	addressIDs := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		// the transactions are looked up by address, whatever the memo
+		addressID, _ := memo.SplitAddressID(subscription.AddressID())
+		addressIDs = append(addressIDs, addressID)
+	}
+	p.Add(addressIDs)
+}
+
+func (p *Prefilter) observe() {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.bloom == nil {
+		return
+	}
+	metrics.ObservePrefilter(p.bloom.sizeBytes(), p.bloom.falsePositiveRate())
+}
+
+// bloom is a bloom filter with k indexes derived from two FNV hashes of the key
+type bloom struct {
+	bits  []uint64
+	m     uint64
+	k     uint64
+	count uint64
+}
+
+// newBloom sizes the filter for capacity keys at the false positive rate
+func newBloom(capacity int, falsePositiveRate float64) *bloom {
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/float64(capacity)*math.Ln2)))
+	return &bloom{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+func (b *bloom) add(key string) {
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		index := (h1 + i*h2) % b.m
+		b.bits[index/64] |= 1 << (index % 64)
+	}
+	b.count++
+}
+
+func (b *bloom) mayContain(key string) bool {
+	h1, h2 := bloomHashes(key)
+	for i := uint64(0); i < b.k; i++ {
+		index := (h1 + i*h2) % b.m
+		if b.bits[index/64]&(1<<(index%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// falsePositiveRate estimates the rate for the keys added so far
+func (b *bloom) falsePositiveRate() float64 {
+	return math.Pow(1-math.Exp(-float64(b.k)*float64(b.count)/float64(b.m)), float64(b.k))
+}
+
+func (b *bloom) sizeBytes() int {
+	return len(b.bits) * 8
+}
+
+func bloomHashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	_, _ = h1.Write([]byte(key))
+	h2 := fnv.New64()
+	_, _ = h2.Write([]byte(key))
+	return h1.Sum64(), h2.Sum64() | 1
+}
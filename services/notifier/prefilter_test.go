@@ -0,0 +1,41 @@
+package notifier
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloom(t *testing.T) {
+	filter := newBloom(1000, 0.01)
+	assert.Equal(t, uint64(7), filter.k)
+	assert.Zero(t, filter.falsePositiveRate())
+
+	for i := 0; i < 1000; i++ {
+		filter.add(fmt.Sprintf("60_0x%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		assert.True(t, filter.mayContain(fmt.Sprintf("60_0x%d", i)))
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain(fmt.Sprintf("714_bnb%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300)
+	assert.InDelta(t, 0.01, filter.falsePositiveRate(), 0.002)
+}
+
+func TestPrefilter_Filter(t *testing.T) {
+	p := NewPrefilter(nil, 0)
+	assert.Equal(t, defaultFalsePositiveRate, p.falsePositiveRate)
+	assert.Equal(t, []string{"60_0xa", "60_0xb"}, p.Filter([]string{"60_0xa", "60_0xb"}))
+
+	p.bloom = newBloom(minPrefilterCapacity, p.falsePositiveRate)
+	p.apply([]byte(`{"operation":"AddSubscription","subscriptions":{"60":["0xa"]}}`))
+	p.apply([]byte(`{"operation":"DeleteSubscription","subscriptions":{"60":["0xb"]}}`))
+	assert.Equal(t, []string{"60_0xa"}, p.Filter([]string{"60_0xa", "60_0xb"}))
+}
@@ -0,0 +1,115 @@
+package notifier
+
+import (
+	"math/big"
+
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/golibs/types"
+)
+
+// filterNotifications keeps the notifications matching the rules of the subscription
+func filterNotifications(notifications []types.TransactionNotification, rules models.SubscriptionRules) []types.TransactionNotification {
+	result := make([]types.TransactionNotification, 0, len(notifications))
+	for _, notification := range notifications {
+		if matchRules(notification.Result, rules) {
+			result = append(result, notification)
+		}
+	}
+	return result
+}
+
+// filterMemo keeps the notifications carrying the memo a memo scoped subscription is scoped to, the
+// notifications of a plain subscription are all kept
+func filterMemo(notifications []types.TransactionNotification, coinID uint, scope string) []types.TransactionNotification {
+	if scope == "" {
+		return notifications
+	}
+	result := make([]types.TransactionNotification, 0, len(notifications))
+	for _, notification := range notifications {
+		if memo.Normalize(coinID, notification.Result.Memo) == scope {
+			result = append(result, notification)
+		}
+	}
+	return result
+}
+
+// matchRules evaluates the rules on a transaction whose direction was already set for the subscribed address
+func matchRules(tx types.Tx, rules models.SubscriptionRules) bool {
+	if len(rules.Types) > 0 && !containsType(rules.Types, internal.TransactionType(tx)) {
+		return false
+	}
+	if rules.Direction != "" && tx.Direction != rules.Direction {
+		return false
+	}
+	if rules.RequireMemo && tx.Memo == "" {
+		return false
+	}
+	if rules.Memo != "" && tx.Memo != rules.Memo {
+		return false
+	}
+
+	value, tokenID := transactionValue(tx)
+	if tokenID != "" {
+		if len(rules.AllowTokens) > 0 && !containsString(rules.AllowTokens, tokenID) {
+			return false
+		}
+		if containsString(rules.DenyTokens, tokenID) {
+			return false
+		}
+	}
+	if rules.MinAmount != "" && value != "" {
+		min, okMin := new(big.Int).SetString(rules.MinAmount, 10)
+		amount, okAmount := new(big.Int).SetString(value, 10)
+		if okMin && okAmount && amount.Cmp(min) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// transactionValue returns the value of the transaction and the token it moves, empty for the coin itself
+func transactionValue(tx types.Tx) (string, string) {
+	switch meta := tx.Meta.(type) {
+	case types.Transfer:
+		return string(meta.Value), ""
+	case *types.Transfer:
+		return string(meta.Value), ""
+	case types.TokenTransfer:
+		return string(meta.Value), meta.TokenID
+	case *types.TokenTransfer:
+		return string(meta.Value), meta.TokenID
+	case types.NativeTokenTransfer:
+		return string(meta.Value), meta.TokenID
+	case *types.NativeTokenTransfer:
+		return string(meta.Value), meta.TokenID
+	case types.AnyAction:
+		return string(meta.Value), meta.TokenID
+	case *types.AnyAction:
+		return string(meta.Value), meta.TokenID
+	case types.ContractCall:
+		return meta.Value, ""
+	case *types.ContractCall:
+		return meta.Value, ""
+	}
+	return "", ""
+}
+
+func containsType(list []types.TransactionType, value types.TransactionType) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
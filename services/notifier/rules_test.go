@@ -0,0 +1,66 @@
+package notifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestMatchRules(t *testing.T) {
+	transfer := types.Tx{
+		Coin:      60,
+		Direction: types.DirectionIncoming,
+		Meta:      types.Transfer{Value: "1000"},
+	}
+	airdrop := types.Tx{
+		Coin:      60,
+		Direction: types.DirectionIncoming,
+		Meta:      types.TokenTransfer{TokenID: "0xspam", Value: "1"},
+	}
+	deposit := nativeTokenTransfer
+	deposit.Direction = types.DirectionIncoming
+
+	tests := []struct {
+		name  string
+		tx    types.Tx
+		rules models.SubscriptionRules
+		want  bool
+	}{
+		{"no rules", airdrop, models.SubscriptionRules{}, true},
+		{"above min amount", transfer, models.SubscriptionRules{MinAmount: "1000"}, true},
+		{"dust", transfer, models.SubscriptionRules{MinAmount: "1001"}, false},
+		{"allowed type", airdrop, models.SubscriptionRules{Types: []types.TransactionType{types.TxTokenTransfer}}, true},
+		{"other type", transfer, models.SubscriptionRules{Types: []types.TransactionType{types.TxTokenTransfer}}, false},
+		{"direction", transfer, models.SubscriptionRules{Direction: types.DirectionOutgoing}, false},
+		{"denied token", airdrop, models.SubscriptionRules{DenyTokens: []string{"0xspam"}}, false},
+		{"token not allowed", airdrop, models.SubscriptionRules{AllowTokens: []string{"0xusdt"}}, false},
+		{"allow list ignores coin transfers", transfer, models.SubscriptionRules{AllowTokens: []string{"0xusdt"}}, true},
+		{"memo required", transfer, models.SubscriptionRules{RequireMemo: true}, false},
+		{"memo matches", deposit, models.SubscriptionRules{Memo: "test"}, true},
+		{"memo differs", deposit, models.SubscriptionRules{Memo: "other"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchRules(tt.tx, tt.rules))
+		})
+	}
+}
+
+func TestFilterMemo(t *testing.T) {
+	notifications := []types.TransactionNotification{
+		{Result: types.Tx{ID: "1", Coin: coin.RIPPLE, Memo: "12345"}},
+		{Result: types.Tx{ID: "2", Coin: coin.RIPPLE, Memo: "54321"}},
+		{Result: types.Tx{ID: "3", Coin: coin.RIPPLE}},
+	}
+
+	assert.Len(t, filterMemo(notifications, coin.RIPPLE, ""), 3)
+
+	result := filterMemo(notifications, coin.RIPPLE, "12345")
+	assert.Len(t, result, 1)
+	assert.Equal(t, "1", result[0].Result.ID)
+
+	assert.Empty(t, filterMemo(notifications, coin.RIPPLE, "1"))
+}
@@ -0,0 +1,92 @@
+package notifier
+
+import (
+	mapset "github.com/deckarep/golang-set"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/numbers"
+	"github.com/trustwallet/golibs/types"
+)
+
+// groupXpubAddresses returns the derived addresses by xpub and the set of all of them
+func groupXpubAddresses(xpubAddresses []models.XpubAddress) (map[uint][]models.XpubAddress, map[string]bool) {
+	byXpub := make(map[uint][]models.XpubAddress)
+	derived := make(map[string]bool, len(xpubAddresses))
+	for _, address := range xpubAddresses {
+		byXpub[address.XpubID] = append(byXpub[address.XpubID], address)
+		derived[address.Address] = true
+	}
+	return byXpub, derived
+}
+
+// xpubOnly reports whether the subscription only backs an address derived from an xpub, its transactions are
+// notified once with the xpub key. A memo subscription on the address, or one a wallet subscribed on its own,
+// has another key or its own wallets and is notified as well.
+func xpubOnly(subscription models.Subscription, derived map[string]bool, wallets map[uint][]string) bool {
+	return derived[subscription.Key()] && len(wallets[subscription.ID]) == 0
+}
+
+// utxoAddresses adds the addresses of every input and output to tx.GetAddresses, which only has From and To
+func utxoAddresses(tx types.Tx) []string {
+	addresses := tx.GetAddresses()
+	for _, input := range tx.Inputs {
+		addresses = append(addresses, input.Address)
+	}
+	for _, output := range tx.Outputs {
+		addresses = append(addresses, output.Address)
+	}
+	return addresses
+}
+
+// buildWalletNotifications builds one notification per transaction of the wallet of the canonical addresses.
+// The direction is the wallet's and the value nets out the change outputs back to the wallet.
+func buildWalletNotifications(addresses []string, txs types.Txs) []types.TransactionNotification {
+	wallet := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		wallet[address] = true
+	}
+
+	result := make([]types.TransactionNotification, 0)
+	seen := make(map[string]bool)
+	for _, tx := range txs {
+		if seen[tx.ID] {
+			continue
+		}
+		walletSet := mapset.NewSet()
+		for _, address := range utxoAddresses(tx) {
+			if wallet[canonical.Address(tx.Coin, address)] {
+				walletSet.Add(address)
+			}
+		}
+		if walletSet.Cardinality() == 0 {
+			continue
+		}
+		seen[tx.ID] = true
+
+		tx.Direction = types.InferDirection(&tx, walletSet)
+		if len(tx.Inputs) > 0 && len(tx.Outputs) > 0 {
+			tx.Meta = types.Transfer{
+				Value:    walletValue(tx, walletSet),
+				Symbol:   coin.Coins[tx.Coin].Symbol,
+				Decimals: coin.Coins[tx.Coin].Decimals,
+			}
+		}
+		result = append(result, types.TransactionNotification{Action: tx.Type, Result: tx})
+	}
+	return result
+}
+
+// walletValue is what the wallet received for incoming transactions, what it sent to other addresses for
+// outgoing ones and what moved between its addresses for self transfers
+func walletValue(tx types.Tx, wallet mapset.Set) types.Amount {
+	value := "0"
+	for _, output := range tx.Outputs {
+		toWallet := wallet.Contains(output.Address)
+		if (tx.Direction == types.DirectionOutgoing) == toWallet {
+			continue
+		}
+		value = numbers.AddAmount(value, string(output.Value))
+	}
+	return types.Amount(value)
+}
@@ -0,0 +1,60 @@
+package notifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+var walletTx = types.Tx{
+	ID:   "a1",
+	Coin: coin.BITCOIN,
+	From: "bc1qwalletreceive0",
+	To:   "bc1qmerchant",
+	Inputs: []types.TxOutput{
+		{Address: "bc1qwalletreceive0", Value: "60000"},
+		{Address: "bc1qwalletreceive1", Value: "50000"},
+	},
+	Outputs: []types.TxOutput{
+		{Address: "bc1qmerchant", Value: "70000"},
+		{Address: "bc1qwalletchange0", Value: "39000"},
+	},
+	Fee:  "1000",
+	Type: types.TxTransfer,
+	Meta: types.Transfer{Value: "70000"},
+}
+
+func TestBuildWalletNotifications(t *testing.T) {
+	wallet := []string{"bc1qwalletreceive0", "bc1qwalletreceive1", "bc1qwalletchange0"}
+
+	notifications := buildWalletNotifications(wallet, types.Txs{walletTx, walletTx})
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, types.DirectionOutgoing, notifications[0].Result.Direction)
+	assert.Equal(t, types.Amount("70000"), notifications[0].Result.Meta.(types.Transfer).Value)
+
+	notifications = buildWalletNotifications([]string{"bc1qmerchant"}, types.Txs{walletTx})
+	assert.Len(t, notifications, 1)
+	assert.Equal(t, types.DirectionIncoming, notifications[0].Result.Direction)
+	assert.Equal(t, types.Amount("70000"), notifications[0].Result.Meta.(types.Transfer).Value)
+
+	self := walletTx
+	self.Outputs = []types.TxOutput{{Address: "bc1qwalletchange0", Value: "109000"}}
+	notifications = buildWalletNotifications(wallet, types.Txs{self})
+	assert.Equal(t, types.DirectionSelf, notifications[0].Result.Direction)
+	assert.Equal(t, types.Amount("109000"), notifications[0].Result.Meta.(types.Transfer).Value)
+
+	assert.Empty(t, buildWalletNotifications([]string{"bc1qother"}, types.Txs{walletTx}))
+}
+
+func TestXpubOnly(t *testing.T) {
+	derived := map[string]bool{"0_bc1qwalletreceive0": true}
+	wallets := map[uint][]string{2: {"wallet"}}
+
+	assert.True(t, xpubOnly(models.Subscription{ID: 1, Address: "0_bc1qwalletreceive0"}, derived, wallets))
+	assert.False(t, xpubOnly(models.Subscription{ID: 2, Address: "0_bc1qwalletreceive0"}, derived, wallets))
+	assert.False(t, xpubOnly(models.Subscription{ID: 3, Address: "0_bc1qwalletreceive0", Memo: "1"}, derived, wallets))
+	assert.False(t, xpubOnly(models.Subscription{ID: 4, Address: "0_bc1qmerchant"}, derived, wallets))
+}
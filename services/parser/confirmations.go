@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/golibs/types"
+)
+
+// ConfirmationThresholds sorts the confirmation thresholds of a coin, dropping the duplicates and the ones
+// below one block
+func ConfirmationThresholds(thresholds []int64) []int64 {
+	seen := make(map[int64]bool, len(thresholds))
+	result := make([]int64, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		if threshold < 1 || seen[threshold] {
+			continue
+		}
+		seen[threshold] = true
+		result = append(result, threshold)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+// tracksConfirmations reports whether the coin is parsed up to the chain head and its transactions notified again
+// at each of the Confirmations thresholds, the last one final. Otherwise the parser waits for the MinConfirmations
+// of the coin and notifies once.
+func (p Params) tracksConfirmations() bool {
+	return len(p.Confirmations) > 0
+}
+
+// revertedConfirmation is the confirmation of the transactions of orphaned blocks, nil unless the coin tracks
+// confirmations
+func (p Params) revertedConfirmation() *internal.Confirmation {
+	if !p.tracksConfirmations() {
+		return nil
+	}
+	return &internal.Confirmation{Status: internal.ConfirmationReverted}
+}
+
+// ValidateConfirmations checks the confirmations of a coin can be tracked: its API must return block headers for
+// the reorganizations to be detected, and ReorgDepth must reach the last threshold, below which a transaction
+// can still be reverted
+func ValidateConfirmations(api blockatlas.BlockAPI, thresholds []int64, reorgDepth int64) error {
+	if len(thresholds) == 0 {
+		return nil
+	}
+	if _, ok := api.(blockatlas.BlockHeaderAPI); !ok {
+		return fmt.Errorf("confirmations of %s need block headers, its API doesn't return them", api.Coin().Handle)
+	}
+	if last := thresholds[len(thresholds)-1]; reorgDepth < last {
+		return fmt.Errorf("confirmations of %s need a reorg depth of at least %d, got %d", api.Coin().Handle, last, reorgDepth)
+	}
+	return nil
+}
+
+// confirmationAt returns the confirmation of the transactions of a block depth blocks below the parsed height,
+// along with the last threshold the depth reached, 0 for none
+func confirmationAt(thresholds []int64, depth int64) (internal.Confirmation, int64) {
+	confirmation := internal.Confirmation{Status: internal.ConfirmationSeen, Confirmations: depth}
+	var reached int64
+	for _, threshold := range thresholds {
+		if depth < threshold {
+			break
+		}
+		reached = threshold
+		confirmation.Status = internal.ConfirmationConfirmed
+	}
+	if reached == thresholds[len(thresholds)-1] {
+		confirmation.Status = internal.ConfirmationFinal
+	}
+	return confirmation, reached
+}
+
+// confirmBlocks queues the transactions of the new blocks at their depth below height, and those of the blocks
+// notified before which reached another threshold since. It returns the messages to queue, the confirmation
+// states to save and the numbers of the blocks which reached the last threshold.
+func confirmBlocks(params Params, blocks []Block, height int64) ([]models.OutboxMessage, []models.ConfirmationState, []int64, error) {
+	var (
+		messages = make([]models.OutboxMessage, 0)
+		pending  = make([]models.ConfirmationState, 0)
+		final    = make([]int64, 0)
+	)
+	states, err := params.Database.GetConfirmationStates(params.Api.Coin().Handle, height-params.Confirmations[0])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, state := range states {
+		confirmation, reached := confirmationAt(params.Confirmations, height-state.Number)
+		if reached <= state.Confirmations {
+			continue
+		}
+		var txs types.Txs
+		if err := json.Unmarshal(state.Txs, &txs); err != nil {
+			return nil, nil, nil, err
+		}
+		blockMessages, err := newConfirmationMessages(params, state.Number, txs, confirmation)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		messages = append(messages, blockMessages...)
+		if confirmation.Status == internal.ConfirmationFinal {
+			final = append(final, state.Number)
+			continue
+		}
+		state.Confirmations = reached
+		pending = append(pending, state)
+	}
+
+	for _, block := range blocks {
+		txs := memo.FilterTransactions(block.Txs)
+		if len(txs) == 0 {
+			continue
+		}
+		blockMessages, state, err := confirmBlock(params, block.Number, txs, height)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		messages = append(messages, blockMessages...)
+		if state != nil {
+			pending = append(pending, *state)
+		}
+	}
+	return messages, pending, final, nil
+}
+
+// confirmBlock queues the transactions of a block parsed for the first time at its depth below height, and returns
+// the confirmation state to save unless it is final already
+func confirmBlock(params Params, number int64, txs types.Txs, height int64) ([]models.OutboxMessage, *models.ConfirmationState, error) {
+	confirmation, reached := confirmationAt(params.Confirmations, height-number)
+	messages, err := newConfirmationMessages(params, number, txs, confirmation)
+	if err != nil {
+		return nil, nil, err
+	}
+	if confirmation.Status == internal.ConfirmationFinal {
+		return messages, nil, nil
+	}
+	raw, err := json.Marshal(txs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return messages, &models.ConfirmationState{
+		Coin:          params.Api.Coin().Handle,
+		Number:        number,
+		Confirmations: reached,
+		Txs:           raw,
+	}, nil
+}
+
+// newConfirmationMessages queues the transactions of a block with their confirmation, the batch id tells the
+// thresholds apart
+func newConfirmationMessages(params Params, number int64, txs types.Txs, confirmation internal.Confirmation) ([]models.OutboxMessage, error) {
+	kind := confirmation.Status
+	if confirmation.Status != internal.ConfirmationSeen {
+		kind = fmt.Sprintf("%s-%d", confirmation.Status, confirmation.Confirmations)
+	}
+	return newOutboxMessages(params, kind, number, number, txs, &confirmation)
+}
@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestConfirmationThresholds(t *testing.T) {
+	assert.Equal(t, []int64{1, 3, 6}, ConfirmationThresholds([]int64{6, 0, 3, 1, 3, -2}))
+	assert.Empty(t, ConfirmationThresholds(nil))
+}
+
+func TestConfirmationAt(t *testing.T) {
+	thresholds := []int64{1, 3, 6}
+	tests := []struct {
+		depth   int64
+		status  string
+		reached int64
+	}{
+		{0, internal.ConfirmationSeen, 0},
+		{1, internal.ConfirmationConfirmed, 1},
+		{2, internal.ConfirmationConfirmed, 1},
+		{3, internal.ConfirmationConfirmed, 3},
+		{6, internal.ConfirmationFinal, 6},
+		{10, internal.ConfirmationFinal, 6},
+	}
+	for _, tt := range tests {
+		confirmation, reached := confirmationAt(thresholds, tt.depth)
+		assert.Equal(t, tt.status, confirmation.Status, tt.depth)
+		assert.Equal(t, tt.depth, confirmation.Confirmations)
+		assert.Equal(t, tt.reached, reached, tt.depth)
+	}
+}
+
+func TestConfirmBlock(t *testing.T) {
+	params := Params{Api: getMockedBlockAPI(), Confirmations: []int64{1, 3}}
+	txs := types.Txs{{
+		ID:   "tx",
+		Coin: coin.ETHEREUM,
+		Fee:  "1",
+		Meta: types.Transfer{Value: "1", Symbol: "ETH", Decimals: 18},
+	}}
+
+	messages, state, err := confirmBlock(params, 10, txs, 11)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	_, envelope, err := internal.DecodeTransactions(messages[0].Body)
+	assert.Nil(t, err)
+	assert.Equal(t, &internal.Confirmation{Status: internal.ConfirmationConfirmed, Confirmations: 1}, envelope.Confirmation)
+	assert.Equal(t, "confirmed-1:ethereum:10-10:ethereum.transfer", envelope.BatchID)
+	assert.Equal(t, int64(10), state.Number)
+	assert.Equal(t, int64(1), state.Confirmations)
+
+	messages, state, err = confirmBlock(params, 10, txs, 13)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Nil(t, state)
+	_, envelope, err = internal.DecodeTransactions(messages[0].Body)
+	assert.Nil(t, err)
+	assert.Equal(t, internal.ConfirmationFinal, envelope.Confirmation.Status)
+}
+
+func TestValidateConfirmations(t *testing.T) {
+	headerAPI := &headerPlatform{Platform: Platform{CoinIndex: coin.BITCOIN}}
+	assert.Nil(t, ValidateConfirmations(getMockedBlockAPI(), nil, 0))
+	assert.Nil(t, ValidateConfirmations(headerAPI, []int64{1, 3, 6}, 6))
+	assert.NotNil(t, ValidateConfirmations(headerAPI, []int64{1, 3, 6}, 5))
+	assert.NotNil(t, ValidateConfirmations(getMockedBlockAPI(), []int64{1, 3}, 10))
+}
+
+func TestRevertedConfirmation(t *testing.T) {
+	assert.Nil(t, Params{}.revertedConfirmation())
+	assert.Equal(t, &internal.Confirmation{Status: internal.ConfirmationReverted}, Params{Confirmations: []int64{1}}.revertedConfirmation())
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/golibs/types"
+)
+
+// RunFailedBlocksRetry periodically refetches the heights the parser handed over and queues their transactions.
+// A height failing MaxBlockAttempts more times is parked until an operator retries or skips it with
+// cmd/failedblocks.
+func RunFailedBlocksRetry(params Params, ctx context.Context) {
+	if params.MaxBlockAttempts <= 0 || params.RetryBlocksInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(params.RetryBlocksInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info(fmt.Sprintf("Failed blocks retry of %s stopped", params.Api.Coin().Handle))
+			return
+		case <-ticker.C:
+			retryFailedBlocks(params)
+		}
+	}
+}
+
+func retryFailedBlocks(params Params) {
+	if !params.isLeader() {
+		return
+	}
+	coin := params.Api.Coin().Handle
+	failedBlocks, err := params.Database.GetFailedBlocksToRetry(
+		coin,
+		params.MaxBlockAttempts,
+		time.Now().Add(-params.RetryBlocksInterval),
+		int(params.MaxBlocks),
+	)
+	if err != nil {
+		log.WithFields(log.Fields{"operation": "run GetFailedBlocksToRetry", "coin": coin}).Error(err)
+		return
+	}
+
+	for _, failedBlock := range failedBlocks {
+		block, err := params.scheduler().FetchBlock(params.Api, failedBlock.Number)
+		if err != nil {
+			metrics.IncRetriedBlocks(coin, metrics.ResultFailed)
+			fields := log.Fields{
+				"coin":     coin,
+				"block":    failedBlock.Number,
+				"attempts": failedBlock.Attempts + 1,
+				"tags":     raven.Tags{{Key: "coin", Value: coin}},
+			}
+			log.WithFields(fields).Error(err)
+			parked, err := params.Database.FailRetriedBlock(coin, failedBlock.Number, err.Error(), params.MaxBlockAttempts)
+			if err != nil {
+				log.WithFields(log.Fields{"operation": "run FailRetriedBlock", "coin": coin}).Error(err)
+			}
+			if parked {
+				metrics.IncRetriedBlocks(coin, metrics.ResultParked)
+				log.WithFields(fields).Error("Failed block parked, retry or skip it with failedblocks")
+			}
+			continue
+		}
+
+		txs := memo.FilterTransactions(block.Txs)
+		messages, pending, err := retriedBlockMessages(params, failedBlock.Number, txs)
+		if err != nil {
+			log.WithFields(log.Fields{"operation": "run retriedBlockMessages", "coin": coin, "block": failedBlock.Number}).Error(err)
+			continue
+		}
+		if err := params.Database.ResolveFailedBlock(coin, params.leaseHolder(), failedBlock.Number, messages, pending); err != nil {
+			log.WithFields(log.Fields{"operation": "run ResolveFailedBlock", "coin": coin}).Error(err)
+			continue
+		}
+
+		metrics.IncRetriedBlocks(coin, metrics.ResultRecovered)
+
+		log.WithFields(log.Fields{
+			"coin":         coin,
+			"block":        failedBlock.Number,
+			"transactions": len(txs),
+		}).Info("Recovered failed block")
+	}
+}
+
+// retriedBlockMessages queues the transactions of a recovered block. Coins tracking confirmations queue them at the
+// depth of the block below the parsed height, and return its confirmation state unless it is final.
+func retriedBlockMessages(params Params, number int64, txs types.Txs) ([]models.OutboxMessage, []models.ConfirmationState, error) {
+	if !params.tracksConfirmations() || len(txs) == 0 {
+		messages, err := newOutboxMessages(params, batchRetried, number, number, txs, nil)
+		return messages, nil, err
+	}
+	tracker, err := params.Database.GetLastParsedBlockNumber(params.Api.Coin().Handle)
+	if err != nil {
+		return nil, nil, err
+	}
+	messages, state, err := confirmBlock(params, number, txs, tracker.Height)
+	if err != nil || state == nil {
+		return messages, nil, err
+	}
+	return messages, []models.ConfirmationState{*state}, nil
+}
+
+// saveFailedBlocks records the failed heights and returns the ones the parser no longer waits for
+func saveFailedBlocks(params Params, failed map[int64]error) (map[int64]bool, error) {
+	handedOff := make(map[int64]bool)
+	if len(failed) == 0 {
+		return handedOff, nil
+	}
+
+	errs := make(map[int64]string, len(failed))
+	for number, err := range failed {
+		errs[number] = err.Error()
+	}
+	failedBlocks, err := params.Database.AddFailedBlocks(params.Api.Coin().Handle, errs)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, failedBlock := range failedBlocks {
+		if failedBlock.Skipped || (params.MaxBlockAttempts > 0 && failedBlock.Attempts >= params.MaxBlockAttempts) {
+			handedOff[failedBlock.Number] = true
+		}
+	}
+	return handedOff, nil
+}
+
+func resolveFailedBlocks(params Params, blocks []Block) error {
+	numbers := make([]int64, 0, len(blocks))
+	for _, block := range blocks {
+		numbers = append(numbers, block.Number)
+	}
+	return params.Database.DeleteFailedBlocks(params.Api.Coin().Handle, numbers)
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/db"
+)
+
+// Lease keeps the database lease of one coin so that only one parser replica parses it at a time.
+// It is considered held until ttl after the start of the last successful renewal, so a replica that
+// can't reach the database stops parsing before another one can take the coin over.
+type Lease struct {
+	coin     string
+	holder   string
+	ttl      time.Duration
+	database *db.Instance
+
+	mu    sync.RWMutex
+	until time.Time
+}
+
+func NewLease(database *db.Instance, coin, holder string, ttl time.Duration) *Lease {
+	return &Lease{
+		coin:     coin,
+		holder:   holder,
+		ttl:      ttl,
+		database: database,
+	}
+}
+
+// Holder is the name the lease is held under
+func (l *Lease) Holder() string {
+	return l.holder
+}
+
+// Held reports whether the lease is held right now
+func (l *Lease) Held() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return time.Now().Before(l.until)
+}
+
+// Run renews the lease every third of its ttl until the context is done, then releases it
+func (l *Lease) Run(ctx context.Context) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		l.renew()
+		select {
+		case <-ctx.Done():
+			l.release()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (l *Lease) renew() {
+	start := time.Now()
+	wasHeld := l.Held()
+	acquired, err := l.database.AcquireLease(l.coin, l.holder, l.ttl)
+	if err != nil {
+		log.WithFields(log.Fields{"operation": "run AcquireLease", "coin": l.coin, "holder": l.holder}).Error(err)
+		return
+	}
+
+	l.mu.Lock()
+	if acquired {
+		l.until = start.Add(l.ttl)
+	} else {
+		l.until = time.Time{}
+	}
+	l.mu.Unlock()
+
+	if acquired != wasHeld {
+		log.WithFields(log.Fields{"coin": l.coin, "holder": l.holder, "held": acquired}).Info("Lease changed")
+	}
+}
+
+func (l *Lease) release() {
+	l.mu.Lock()
+	l.until = time.Time{}
+	l.mu.Unlock()
+	if err := l.database.ReleaseLease(l.coin, l.holder); err != nil {
+		log.WithFields(log.Fields{"operation": "run ReleaseLease", "coin": l.coin, "holder": l.holder}).Error(err)
+	}
+}
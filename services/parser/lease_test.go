@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParams_isLeader(t *testing.T) {
+	assert.True(t, Params{}.isLeader())
+
+	lease := NewLease(nil, "ethereum", "a", time.Minute)
+	assert.False(t, Params{Lease: lease}.isLeader())
+
+	lease.until = time.Now().Add(time.Second)
+	assert.True(t, Params{Lease: lease}.isLeader())
+
+	lease.until = time.Now().Add(-time.Second)
+	assert.False(t, Params{Lease: lease}.isLeader())
+}
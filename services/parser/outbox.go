@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/golibs/types"
+)
+
+const (
+	batchParsed   = "parsed"
+	batchReverted = "reverted"
+	batchRetried  = "retried"
+
+	outboxBatchSize       = 100
+	defaultOutboxInterval = time.Second
+)
+
+// RunOutboxRelay publishes the queued transactions of the coin in order and marks them delivered once RabbitMQ
+// confirmed them. A message nacked or not confirmed in time stays queued and is published again, so a consumer may
+// see it more than once.
+func RunOutboxRelay(params Params, ctx context.Context) {
+	interval := params.OutboxInterval
+	if interval <= 0 {
+		interval = defaultOutboxInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info(fmt.Sprintf("Outbox relay of %s stopped", params.Api.Coin().Handle))
+			return
+		case <-ticker.C:
+			relayOutbox(params)
+		}
+	}
+}
+
+func relayOutbox(params Params) {
+	if !params.isLeader() {
+		return
+	}
+	coin := params.Api.Coin().Handle
+	for {
+		messages, err := params.Database.GetOutboxMessages(coin, outboxBatchSize)
+		if err != nil {
+			log.WithFields(log.Fields{"operation": "run GetOutboxMessages", "coin": coin}).Error(err)
+			return
+		}
+
+		var (
+			delivered    = make([]uint64, 0, len(messages))
+			transactions int
+		)
+		for _, message := range messages {
+			if err = params.Publisher.PublishWithRoutingKey(params.TransactionsExchange, message.RoutingKey, nil, message.Body); err != nil {
+				log.WithFields(log.Fields{
+					"coin":    coin,
+					"message": message.ID,
+					"tags":    raven.Tags{{Key: "coin", Value: coin}},
+				}).Error(err)
+				break
+			}
+			delivered = append(delivered, message.ID)
+			transactions += message.Transactions
+		}
+		metrics.AddPublished(coin, metrics.SourceBlocks, len(delivered), transactions)
+		if err := params.Database.MarkOutboxMessagesDelivered(delivered); err != nil {
+			log.WithFields(log.Fields{"operation": "run MarkOutboxMessagesDelivered", "coin": coin}).Error(err)
+			return
+		}
+		if len(delivered) > 0 {
+			log.WithFields(log.Fields{"coin": coin, "messages": len(delivered)}).Info("Relayed outbox messages")
+		}
+		if err != nil || len(messages) < outboxBatchSize {
+			break
+		}
+	}
+
+	if params.OutboxRetention > 0 {
+		if err := params.Database.DeleteDeliveredOutboxMessages(coin, time.Now().Add(-params.OutboxRetention)); err != nil {
+			log.WithFields(log.Fields{"operation": "run DeleteDeliveredOutboxMessages", "coin": coin}).Error(err)
+		}
+	}
+}
+
+// newOutboxMessages serializes the transactions of the blocks from-to into envelopes, grouped by routing key and
+// chunked under MaxMessageBytes. The batch id is derived from the kind and the block range, so a consumer sees the
+// same id when the same blocks are published again. The confirmation is only set for the coins notified before
+// their final confirmation.
+func newOutboxMessages(params Params, kind string, from, to int64, txs types.Txs, confirmation *internal.Confirmation) ([]models.OutboxMessage, error) {
+	groups, keys := internal.GroupByRoutingKey(txs)
+	messages := make([]models.OutboxMessage, 0, len(keys))
+	for _, key := range keys {
+		batch := internal.Batch{
+			ID:           fmt.Sprintf("%s:%s:%d-%d:%s", kind, params.Api.Coin().Handle, from, to, key),
+			Coin:         params.Api.Coin().ID,
+			FromBlock:    from,
+			ToBlock:      to,
+			Confirmation: confirmation,
+		}
+		envelopes, err := batch.Envelopes(groups[key], params.MaxMessageBytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, envelope := range envelopes {
+			body, err := json.Marshal(envelope)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, models.OutboxMessage{
+				Coin:         params.Api.Coin().Handle,
+				RoutingKey:   key,
+				Transactions: envelope.Count,
+				Body:         body,
+			})
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,57 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestNewOutboxMessages(t *testing.T) {
+	params := Params{Api: getMockedBlockAPI()}
+
+	messages, err := newOutboxMessages(params, batchParsed, 1, 2, nil, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, messages)
+
+	txs := types.Txs{{
+		ID:   "tx",
+		Coin: coin.ETHEREUM,
+		Fee:  "1",
+		Meta: types.Transfer{Value: "1", Symbol: "ETH", Decimals: 18},
+	}}
+	messages, err = newOutboxMessages(params, batchParsed, 1, 2, txs, nil)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, params.Api.Coin().Handle, messages[0].Coin)
+	assert.Equal(t, "ethereum.transfer", messages[0].RoutingKey)
+	assert.Equal(t, 1, messages[0].Transactions)
+
+	decoded, envelope, err := internal.DecodeTransactions(messages[0].Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "tx", decoded[0].ID)
+	assert.Equal(t, "parsed:ethereum:1-2:ethereum.transfer", envelope.BatchID)
+	assert.Equal(t, int64(1), envelope.FromBlock)
+	assert.Equal(t, int64(2), envelope.ToBlock)
+}
+
+func TestNewOutboxMessages_Chunks(t *testing.T) {
+	params := Params{Api: getMockedBlockAPI(), MaxMessageBytes: 1}
+
+	txs := types.Txs{
+		{ID: "a", Coin: coin.ETHEREUM, Fee: "1", Meta: types.Transfer{Value: "1", Symbol: "ETH", Decimals: 18}},
+		{ID: "b", Coin: coin.ETHEREUM, Fee: "1", Meta: types.Transfer{Value: "2", Symbol: "ETH", Decimals: 18}},
+	}
+	messages, err := newOutboxMessages(params, batchParsed, 5, 5, txs, nil)
+	assert.Nil(t, err)
+	assert.Len(t, messages, 2)
+	for i, message := range messages {
+		decoded, envelope, err := internal.DecodeTransactions(message.Body)
+		assert.Nil(t, err)
+		assert.Len(t, decoded, 1)
+		assert.Equal(t, i, envelope.Chunk)
+		assert.Equal(t, 2, envelope.Chunks)
+	}
+}
@@ -2,11 +2,9 @@ package parser
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strconv"
-	"sync/atomic"
 
 	"github.com/trustwallet/blockatlas/db/models"
 
@@ -14,11 +12,13 @@ import (
 
 	"math/rand"
 	"sort"
-	"sync"
 	"time"
 
 	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/internal/metrics"
 	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/memo"
 	"github.com/trustwallet/golibs/network/mq"
 	"github.com/trustwallet/golibs/numbers"
 	"github.com/trustwallet/golibs/types"
@@ -28,16 +28,33 @@ import (
 
 type (
 	Params struct {
-		Api                                       blockatlas.BlockAPI
-		TransactionsExchange                      mq.Exchange
-		ParsingBlocksInterval, FetchBlocksTimeout time.Duration
-		MaxBlocks                                 int64
-		StopChannel                               chan<- struct{}
-		Database                                  *db.Instance
+		Api                   blockatlas.BlockAPI
+		TransactionsExchange  mq.Exchange
+		Publisher             *internal.Publisher
+		ParsingBlocksInterval time.Duration
+		MaxBlocks             int64
+		ReorgDepth            int64
+		MaxBlockAttempts      int
+		RetryBlocksInterval   time.Duration
+		Priorities            map[string]PriorityOptions
+		Poller                *Poller
+		MaxMessageBytes       int
+		Scheduler             *Scheduler
+		Lease                 *Lease
+		OutboxInterval        time.Duration
+		OutboxRetention       time.Duration
+		Confirmations         []int64
+		Database              *db.Instance
 	}
 
 	GetBlockByNumber func(num int64) (*types.Block, error)
 
+	// Block is a fetched block with its header, if the platform provides one
+	Block struct {
+		types.Block
+		Header *blockatlas.BlockHeader
+	}
+
 	stop struct {
 		error
 	}
@@ -49,7 +66,6 @@ func RunParser(params Params, ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			log.Info(fmt.Sprintf("Parser of %s stopped parsing blocks", params.Api.Coin().Handle))
-			params.StopChannel <- struct{}{}
 			return
 		default:
 			parse(params)
@@ -58,6 +74,26 @@ func RunParser(params Params, ctx context.Context) {
 	}
 }
 
+func (p Params) scheduler() *Scheduler {
+	if p.Scheduler == nil {
+		return NewScheduler(int(p.MaxBlocks)+1, 0)
+	}
+	return p.Scheduler
+}
+
+// isLeader reports whether this replica may parse the coin, parsing is unrestricted without a lease
+func (p Params) isLeader() bool {
+	return p.Lease == nil || p.Lease.Held()
+}
+
+// leaseHolder fences the writes of the parser with the lease, empty without one
+func (p Params) leaseHolder() string {
+	if p.Lease == nil {
+		return ""
+	}
+	return p.Lease.Holder()
+}
+
 func GetInterval(value int, minInterval, maxInterval time.Duration) time.Duration {
 	interval := time.Duration(value) * time.Millisecond
 	pMin := numbers.Max(minInterval.Nanoseconds(), interval.Nanoseconds())
@@ -66,6 +102,11 @@ func GetInterval(value int, minInterval, maxInterval time.Duration) time.Duratio
 }
 
 func parse(params Params) {
+	if !params.isLeader() {
+		time.Sleep(params.ParsingBlocksInterval)
+		return
+	}
+
 	coinTracker, err := params.Database.GetLastParsedBlockNumber(params.Api.Coin().Handle)
 	if err != nil {
 		time.Sleep(params.ParsingBlocksInterval)
@@ -76,6 +117,13 @@ func parse(params Params) {
 		return
 	}
 
+	if params.Poller != nil {
+		params.ParsingBlocksInterval = params.Poller.Interval()
+	}
+	// Priority is read from the tracker on every step, so operators can change it at runtime
+	params = params.withPriority(coinTracker.Priority)
+	params.scheduler().SetPriority(coinTracker.Priority)
+
 	lastParsedBlock, currentBlock, err := GetBlocksIntervalToFetch(params, coinTracker)
 	if err != nil {
 		time.Sleep(params.ParsingBlocksInterval)
@@ -86,46 +134,86 @@ func parse(params Params) {
 		time.Sleep(params.ParsingBlocksInterval)
 		return
 	}
+	if lastParsedBlock == currentBlock && params.Poller != nil {
+		params.Poller.NoNewBlocks()
+	}
 
-	blocks, err := FetchBlocks(params, lastParsedBlock, currentBlock)
+	blocks, failed, err := FetchBlocks(params, lastParsedBlock, currentBlock)
 	if err != nil {
 		time.Sleep(params.ParsingBlocksInterval)
 		return
 	}
 
-	err = SaveLastParsedBlock(params, blocks)
+	handedOff, err := saveFailedBlocks(params, failed)
 	if err != nil {
 		log.WithFields(log.Fields{
-			"operation":       "run SaveLastParsedBlock",
-			"coin":            params.Api.Coin().Handle,
-			"blocks":          blocks,
-			"lastParsedBlock": lastParsedBlock,
-			"currentBlock":    currentBlock,
-			"tags":            raven.Tags{{Key: "coin", Value: params.Api.Coin().Handle}},
+			"operation": "run saveFailedBlocks",
+			"coin":      params.Api.Coin().Handle,
+			"tags":      raven.Tags{{Key: "coin", Value: params.Api.Coin().Handle}},
 		}).Error(err)
 		time.Sleep(params.ParsingBlocksInterval)
 		return
 	}
 
-	var txs types.Txs
-	for _, block := range blocks {
-		txs = append(txs, block.Txs...)
+	blocks, lastBlockNumber := GetContiguousBlocks(blocks, lastParsedBlock, currentBlock, handedOff)
+	if lastBlockNumber < lastParsedBlock {
+		time.Sleep(params.ParsingBlocksInterval)
+		return
+	}
+
+	reorg, err := handleReorg(params, blocks)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"operation": "run handleReorg",
+			"coin":      params.Api.Coin().Handle,
+			"tags":      raven.Tags{{Key: "coin", Value: params.Api.Coin().Handle}},
+		}).Error(err)
+		time.Sleep(params.ParsingBlocksInterval)
+		return
+	}
+	if reorg {
+		return
 	}
-	txs = txs.FilterTransactionsByMemo()
 
-	err = publish(params, txs)
+	// The lease may have expired while fetching, another replica could be parsing the same blocks by now.
+	// The save is fenced by the lease too, for an expiry between this check and the write.
+	if !params.isLeader() {
+		log.WithFields(log.Fields{"coin": params.Api.Coin().Handle}).Warn("Lease lost, dropping parsed blocks")
+		return
+	}
+
+	txs, err := SaveLastParsedBlock(params, blocks, lastBlockNumber)
+	if errors.Is(err, db.ErrLeaseLost) {
+		log.WithFields(log.Fields{"coin": params.Api.Coin().Handle}).Warn("Lease lost, dropping parsed blocks")
+		return
+	}
 	if err != nil {
 		log.WithFields(log.Fields{
-			"coin":         params.Api.Coin().Handle,
-			"transactions": len(txs),
-			"error":        err,
-		}).Info("Publish Error")
+			"operation":       "run SaveLastParsedBlock",
+			"coin":            params.Api.Coin().Handle,
+			"blocks":          blocks,
+			"lastParsedBlock": lastParsedBlock,
+			"currentBlock":    currentBlock,
+			"tags":            raven.Tags{{Key: "coin", Value: params.Api.Coin().Handle}},
+		}).Error(err)
+		time.Sleep(params.ParsingBlocksInterval)
+		return
 	}
 
 	log.WithFields(log.Fields{
 		"coin":         params.Api.Coin().Handle,
 		"transactions": len(txs),
-	}).Info("Published transactions")
+	}).Info("Queued transactions")
+
+	if params.Poller != nil {
+		params.Poller.Observe(blocks)
+		// The batch is capped by MaxBlocks or stopped at a failed block, more blocks are waiting
+		if currentBlock-lastParsedBlock > params.MaxBlocks || lastBlockNumber < currentBlock-1 {
+			params.Poller.Behind()
+		} else {
+			params.Poller.CaughtUp()
+		}
+	}
 
 	log.WithFields(log.Fields{"coin": params.Api.Coin().Handle}).Info("End of parse step")
 }
@@ -136,7 +224,11 @@ func GetBlocksIntervalToFetch(params Params, tracker models.Tracker) (int64, int
 	if err != nil {
 		return 0, 0, errors.New(err.Error() + "Polling failed: source didn't return chain head number. lastParsedBlock: " + strconv.Itoa(int(lastParsedBlock)))
 	}
-	currentBlock -= params.Api.Coin().MinConfirmations
+	metrics.ObserveChainHead(params.Api.Coin().Handle, currentBlock)
+	metrics.ObserveParsedHeight(params.Api.Coin().Handle, lastParsedBlock)
+	if !params.tracksConfirmations() {
+		currentBlock -= params.Api.Coin().MinConfirmations
+	}
 
 	return GetNextBlocksToParse(lastParsedBlock, currentBlock, params.MaxBlocks)
 }
@@ -159,52 +251,35 @@ func GetNextBlocksToParse(lastParsedBlock int64, currentBlock int64, maxBlocks i
 	return nextBlock, endParseBlock + 1, nil
 }
 
-func FetchBlocks(params Params, lastParsedBlock, currentBlock int64) ([]types.Block, error) {
+// FetchBlocks returns the fetched blocks and the error of every height that failed after retries
+func FetchBlocks(params Params, lastParsedBlock, currentBlock int64) ([]Block, map[int64]error, error) {
 	if lastParsedBlock == currentBlock {
 		log.WithFields(log.Fields{
 			"current_block": lastParsedBlock,
 			"coin":          params.Api.Coin().Handle,
 		}).Info("No new blocks")
-		return nil, errors.New("no new blocks")
+		return nil, nil, errors.New("no new blocks")
 	}
 
 	blocksCount := currentBlock - lastParsedBlock
 	if blocksCount < 0 {
 		log.WithFields(log.Fields{"coin": params.Api.Coin().Handle}).Error("Current block is 0")
-		return nil, errors.New("current block is 0")
+		return nil, nil, errors.New("current block is 0")
 	}
 
-	var (
-		blocksChan = make(chan types.Block, blocksCount)
-		errorsChan = make(chan error, blocksCount)
-		totalCount int32
-		wg         sync.WaitGroup
-	)
-
+	numbers := make([]int64, 0, blocksCount)
 	for i := lastParsedBlock; i <= currentBlock-1; i++ {
-		wg.Add(1)
-		time.Sleep(params.FetchBlocksTimeout)
-		go func(i int64, wg *sync.WaitGroup) {
-			defer wg.Done()
-			err := fetchBlock(params.Api, i, blocksChan)
-			if err != nil {
-				errorsChan <- err
-				return
-			}
-			atomic.AddInt32(&totalCount, 1)
-		}(i, &wg)
-	}
-
-	wg.Wait()
-	close(errorsChan)
-	close(blocksChan)
-
-	if len(errorsChan) > 0 {
+		numbers = append(numbers, i)
+	}
+	blocks, failed := params.scheduler().FetchBlocks(params.Api, numbers)
+
+	if len(failed) > 0 {
+		metrics.AddFailedBlocks(params.Api.Coin().Handle, len(failed))
 		var (
-			errorsList = make([]error, 0, len(errorsChan))
+			errorsList = make([]int64, 0, len(failed))
 		)
-		for err := range errorsChan {
-			errorsList = append(errorsList, err)
+		for number := range failed {
+			errorsList = append(errorsList, number)
 		}
 		log.WithFields(log.Fields{
 			"coin":   params.Api.Coin().Handle,
@@ -214,75 +289,111 @@ func FetchBlocks(params Params, lastParsedBlock, currentBlock int64) ([]types.Bl
 				{Key: "coin", Value: params.Api.Coin().Handle},
 			},
 		}).Error("Fetch Blocks Errors")
-
-		return []types.Block{}, fmt.Errorf("unable to fetch blocks: %d: %d", lastParsedBlock, currentBlock)
 	}
 
-	blocks := make([]types.Block, 0, len(blocksChan))
-	for block := range blocksChan {
-		blocks = append(blocks, block)
-	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Number < blocks[j].Number
+	})
 
 	log.WithFields(log.Fields{
 		"from":  lastParsedBlock,
 		"to":    currentBlock - 1,
-		"total": totalCount,
+		"total": len(blocks),
 		"coin":  params.Api.Coin().Handle},
 	).Info("Fetched blocks batch")
 
-	return blocks, nil
+	return blocks, failed, nil
 }
 
-func fetchBlock(api blockatlas.BlockAPI, num int64, blocksChan chan<- types.Block) error {
-	block, err := getBlockByNumberWithRetry(5, time.Second*5, api.GetBlockByNumber, num, api.Coin().Symbol)
-	if err != nil {
-		return fmt.Errorf("%d", num)
+// GetContiguousBlocks returns the blocks that form an unbroken run from the first height, where handed off
+// heights count as done, and the last height of that run
+func GetContiguousBlocks(blocks []Block, lastParsedBlock, currentBlock int64, handedOff map[int64]bool) ([]Block, int64) {
+	fetched := make(map[int64]Block, len(blocks))
+	for _, block := range blocks {
+		fetched[block.Number] = block
 	}
-	blocksChan <- *block
-	return nil
-}
 
-func SaveLastParsedBlock(params Params, blocks []types.Block) error {
-	if len(blocks) == 0 {
-		return nil
+	result := make([]Block, 0, len(blocks))
+	lastBlockNumber := lastParsedBlock - 1
+	for i := lastParsedBlock; i < currentBlock; i++ {
+		if block, ok := fetched[i]; ok {
+			result = append(result, block)
+		} else if !handedOff[i] {
+			break
+		}
+		lastBlockNumber = i
 	}
+	return result, lastBlockNumber
+}
 
+// SaveLastParsedBlock moves the tracker to lastBlockNumber, stores the hashes of the blocks and queues their
+// transactions in the outbox in the same database transaction, then returns the queued transactions
+func SaveLastParsedBlock(params Params, blocks []Block, lastBlockNumber int64) (types.Txs, error) {
 	sort.Slice(blocks, func(i, j int) bool {
 		return blocks[i].Number < blocks[j].Number
 	})
-	if len(blocks)-1 < 0 {
-		return fmt.Errorf("cannot get last block number for %s", params.Api.Coin().Handle)
-	}
 
-	lastBlockNumber := blocks[len(blocks)-1].Number
 	if lastBlockNumber <= 0 {
-		return fmt.Errorf("parser of %s failed to save last block, lastBlockNumber <= 0: %d", params.Api.Coin().Handle, lastBlockNumber)
+		return nil, fmt.Errorf("parser of %s failed to save last block, lastBlockNumber <= 0: %d", params.Api.Coin().Handle, lastBlockNumber)
 	}
-	err := params.Database.SetLastParsedBlockNumber(params.Api.Coin().Handle, lastBlockNumber)
+	parsed, keepFrom, err := parsedBlocks(params, blocks)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	log.WithFields(log.Fields{
-		"block": lastBlockNumber,
-		"coin":  params.Api.Coin().Handle,
-	}).Info("Save last parsed block")
-
-	return nil
-}
-
-func publish(params Params, transactions types.Txs) error {
+	var txs types.Txs
+	for _, block := range blocks {
+		txs = append(txs, block.Txs...)
+	}
+	txs = memo.FilterTransactions(txs)
 
-	if len(transactions) == 0 {
-		return nil
+	var (
+		messages []models.OutboxMessage
+		pending  []models.ConfirmationState
+		final    []int64
+	)
+	if params.tracksConfirmations() {
+		messages, pending, final, err = confirmBlocks(params, blocks, lastBlockNumber)
+	} else {
+		var from int64
+		if len(blocks) > 0 {
+			from = blocks[0].Number
+		}
+		messages, err = newOutboxMessages(params, batchParsed, from, lastBlockNumber, txs, nil)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	body, err := json.Marshal(transactions)
+	err = params.Database.SaveParseStep(params.Api.Coin().Handle, params.leaseHolder(), db.ParseStep{
+		Height:   lastBlockNumber,
+		Blocks:   parsed,
+		KeepFrom: keepFrom,
+		Messages: messages,
+		Pending:  pending,
+		Final:    final,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = resolveFailedBlocks(params, blocks)
 	if err != nil {
-		log.WithFields(log.Fields{"operation": "publish marshal", "transactions": transactions, "coin": params.Api.Coin().Handle}).Error(err)
-		return err
+		return nil, err
+	}
+
+	metrics.ObserveParsedHeight(params.Api.Coin().Handle, lastBlockNumber)
+	if len(blocks) > 0 && blocks[len(blocks)-1].Number == lastBlockNumber {
+		if timestamp := blockTime(blocks[len(blocks)-1]); timestamp > 0 {
+			metrics.ObserveParsedTime(params.Api.Coin().Handle, timestamp)
+		}
 	}
-	return params.TransactionsExchange.Publish(body)
+
+	log.WithFields(log.Fields{
+		"block": lastBlockNumber,
+		"coin":  params.Api.Coin().Handle,
+	}).Info("Save last parsed block")
+
+	return txs, nil
 }
 
 func getBlockByNumberWithRetry(attempts int, sleep time.Duration, getBlockByNumber GetBlockByNumber, n int64, symbol string) (*types.Block, error) {
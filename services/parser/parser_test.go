@@ -20,16 +20,40 @@ func TestFetchBlocks(t *testing.T) {
 		Api:                   getMockedBlockAPI(),
 		TransactionsExchange:  "",
 		ParsingBlocksInterval: 0,
-		FetchBlocksTimeout:    0,
 		MaxBlocks:             0,
-		StopChannel:           nil,
 		Database:              nil,
 	}
-	blocks, err := FetchBlocks(params, 0, 100)
+	blocks, failed, err := FetchBlocks(params, 0, 100)
 	assert.Equal(t, len(blocks), 100)
+	assert.Empty(t, failed)
 	assert.Nil(t, err)
 }
 
+func TestGetContiguousBlocks(t *testing.T) {
+	blocks := []Block{
+		{Block: types.Block{Number: 11}},
+		{Block: types.Block{Number: 12}},
+		{Block: types.Block{Number: 14}},
+		{Block: types.Block{Number: 16}},
+	}
+
+	result, last := GetContiguousBlocks(blocks, 11, 17, map[int64]bool{})
+	assert.Equal(t, blocks[:2], result)
+	assert.Equal(t, int64(12), last)
+
+	result, last = GetContiguousBlocks(blocks, 11, 17, map[int64]bool{13: true})
+	assert.Equal(t, blocks[:3], result)
+	assert.Equal(t, int64(14), last)
+
+	result, last = GetContiguousBlocks(blocks, 11, 17, map[int64]bool{13: true, 15: true})
+	assert.Equal(t, blocks, result)
+	assert.Equal(t, int64(16), last)
+
+	result, last = GetContiguousBlocks(blocks[1:], 11, 17, map[int64]bool{})
+	assert.Empty(t, result)
+	assert.Equal(t, int64(10), last)
+}
+
 func TestParser_getBlockByNumberWithRetry(t *testing.T) {
 	block, err := getBlockByNumberWithRetry(3, time.Millisecond*1, getBlock, 1, "")
 	if err != nil {
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"sync"
+	"time"
+
+	"github.com/trustwallet/blockatlas/internal/metrics"
+)
+
+const (
+	// weight of a new block time sample in the moving average
+	blockTimeSmoothing = 0.2
+	// growth of the interval on every poll without new blocks
+	backoffFactor = 1.25
+)
+
+// Poller adapts the poll interval of a coin to its block production, learned from the timestamps of the parsed blocks.
+// It polls at the learned block time once caught up, backs off while there are no new blocks and polls at the
+// minimum interval while behind.
+type Poller struct {
+	coin     string
+	min, max time.Duration
+
+	mu        sync.Mutex
+	blockTime time.Duration
+	interval  time.Duration
+	last      blockTimestamp
+}
+
+type blockTimestamp struct {
+	number int64
+	time   int64
+}
+
+// NewPoller starts from the expected block time of the coin, the interval is kept between min and max
+func NewPoller(coin string, blockTime, min, max time.Duration) *Poller {
+	p := &Poller{coin: coin, min: min, max: max, blockTime: blockTime}
+	p.setInterval(blockTime)
+	return p
+}
+
+func (p *Poller) Interval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.interval
+}
+
+func (p *Poller) BlockTime() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.blockTime
+}
+
+// Observe learns the block time from the timestamps of the parsed blocks
+func (p *Poller) Observe(blocks []Block) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, block := range blocks {
+		timestamp := blockTime(block)
+		if timestamp <= 0 {
+			continue
+		}
+		if p.last.time > 0 && block.Number > p.last.number && timestamp >= p.last.time {
+			sample := time.Duration(timestamp-p.last.time) * time.Second / time.Duration(block.Number-p.last.number)
+			p.blockTime = time.Duration(float64(p.blockTime)*(1-blockTimeSmoothing) + float64(sample)*blockTimeSmoothing)
+		}
+		if block.Number > p.last.number {
+			p.last = blockTimestamp{number: block.Number, time: timestamp}
+		}
+	}
+}
+
+// CaughtUp polls at the learned block time
+func (p *Poller) CaughtUp() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setInterval(p.blockTime)
+}
+
+// Behind polls as often as allowed
+func (p *Poller) Behind() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setInterval(p.min)
+}
+
+// NoNewBlocks backs off
+func (p *Poller) NoNewBlocks() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.setInterval(time.Duration(float64(p.interval) * backoffFactor))
+}
+
+func (p *Poller) setInterval(interval time.Duration) {
+	if interval < p.min {
+		interval = p.min
+	}
+	if p.max > 0 && interval > p.max {
+		interval = p.max
+	}
+	p.interval = interval
+	metrics.ObservePolling(p.coin, p.interval.Seconds(), p.blockTime.Seconds())
+}
+
+// blockTime returns the block timestamp in seconds, from the header or else the latest transaction
+func blockTime(block Block) int64 {
+	if block.Header != nil && block.Header.Time > 0 {
+		return block.Header.Time
+	}
+	var timestamp int64
+	for _, tx := range block.Txs {
+		if tx.Date > timestamp {
+			timestamp = tx.Date
+		}
+	}
+	return timestamp
+}
@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestPoller_Observe(t *testing.T) {
+	poller := NewPoller("ethereum", time.Second*10, time.Second, time.Minute)
+	assert.Equal(t, time.Second*10, poller.Interval())
+
+	poller.Observe([]Block{
+		{Block: types.Block{Number: 1}, Header: &blockatlas.BlockHeader{Time: 1000}},
+		{Block: types.Block{Number: 2, Txs: []types.Tx{{Date: 1010}, {Date: 1020}}}},
+		{Block: types.Block{Number: 3}},
+	})
+	// one sample of 20s: 10s * 0.8 + 20s * 0.2
+	assert.Equal(t, time.Second*12, poller.BlockTime())
+
+	// block 3 has no timestamp, the sample spans blocks 2 to 5
+	poller.Observe([]Block{
+		{Block: types.Block{Number: 5}, Header: &blockatlas.BlockHeader{Time: 1080}},
+	})
+	assert.Equal(t, time.Duration(float64(time.Second*12)*0.8+float64(time.Second*20)*0.2), poller.BlockTime())
+}
+
+func TestPoller_Interval(t *testing.T) {
+	poller := NewPoller("ethereum", time.Second*4, time.Second*2, time.Second*6)
+
+	poller.NoNewBlocks()
+	assert.Equal(t, time.Second*5, poller.Interval())
+	poller.NoNewBlocks()
+	assert.Equal(t, time.Second*6, poller.Interval())
+
+	poller.Behind()
+	assert.Equal(t, time.Second*2, poller.Interval())
+
+	poller.CaughtUp()
+	assert.Equal(t, time.Second*4, poller.Interval())
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// PriorityOptions scale the poll interval and the blocks per parse step of the coins with a priority
+type PriorityOptions struct {
+	PollFactor      float64
+	MaxBlocksFactor float64
+}
+
+// FetchBudget bounds the block fetches running at once across all the coins of the process.
+// When it is exhausted, a freed slot goes to the longest waiting fetch of the highest priority.
+type FetchBudget struct {
+	mu      sync.Mutex
+	free    int
+	waiters [3][]chan struct{}
+}
+
+func NewFetchBudget(size int) *FetchBudget {
+	return &FetchBudget{free: size}
+}
+
+func (b *FetchBudget) acquire(priority string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.free > 0 {
+		b.free--
+		b.mu.Unlock()
+		return
+	}
+	ready := make(chan struct{})
+	r := rank(priority)
+	b.waiters[r] = append(b.waiters[r], ready)
+	b.mu.Unlock()
+	<-ready
+}
+
+func (b *FetchBudget) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for r := range b.waiters {
+		if len(b.waiters[r]) == 0 {
+			continue
+		}
+		ready := b.waiters[r][0]
+		b.waiters[r] = b.waiters[r][1:]
+		close(ready)
+		return
+	}
+	b.free++
+}
+
+// rank orders the claims on the fetch budget, unknown priorities count as normal
+func rank(priority string) int {
+	switch priority {
+	case PriorityHigh:
+		return 0
+	case PriorityLow:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// withPriority returns the params of a parse step for a coin with the priority
+func (p Params) withPriority(priority string) Params {
+	options, ok := p.Priorities[priority]
+	if !ok {
+		return p
+	}
+	if options.PollFactor > 0 {
+		p.ParsingBlocksInterval = time.Duration(float64(p.ParsingBlocksInterval) * options.PollFactor)
+	}
+	if options.MaxBlocksFactor > 0 {
+		p.MaxBlocks = int64(float64(p.MaxBlocks) * options.MaxBlocksFactor)
+		if p.MaxBlocks < 1 {
+			p.MaxBlocks = 1
+		}
+	}
+	return p
+}
@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParams_withPriority(t *testing.T) {
+	params := Params{
+		ParsingBlocksInterval: time.Second * 10,
+		MaxBlocks:             10,
+		Priorities: map[string]PriorityOptions{
+			PriorityHigh: {PollFactor: 0.5, MaxBlocksFactor: 2},
+			PriorityLow:  {PollFactor: 2, MaxBlocksFactor: 0.01},
+		},
+	}
+
+	high := params.withPriority(PriorityHigh)
+	assert.Equal(t, time.Second*5, high.ParsingBlocksInterval)
+	assert.Equal(t, int64(20), high.MaxBlocks)
+
+	low := params.withPriority(PriorityLow)
+	assert.Equal(t, time.Second*20, low.ParsingBlocksInterval)
+	assert.Equal(t, int64(1), low.MaxBlocks)
+
+	normal := params.withPriority(PriorityNormal)
+	assert.Equal(t, params.ParsingBlocksInterval, normal.ParsingBlocksInterval)
+	assert.Equal(t, params.MaxBlocks, normal.MaxBlocks)
+}
+
+func TestFetchBudget(t *testing.T) {
+	budget := NewFetchBudget(1)
+	budget.acquire(PriorityNormal)
+
+	order := make(chan string, 3)
+	wait := func(priority string) {
+		budget.acquire(priority)
+		order <- priority
+		budget.release()
+	}
+	go wait(PriorityLow)
+	time.Sleep(time.Millisecond * 20)
+	go wait(PriorityNormal)
+	time.Sleep(time.Millisecond * 20)
+	go wait(PriorityHigh)
+	time.Sleep(time.Millisecond * 20)
+
+	budget.release()
+	assert.Equal(t, PriorityHigh, <-order)
+	assert.Equal(t, PriorityNormal, <-order)
+	assert.Equal(t, PriorityLow, <-order)
+
+	var nilBudget *FetchBudget
+	nilBudget.acquire(PriorityHigh)
+	nilBudget.release()
+}
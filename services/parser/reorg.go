@@ -0,0 +1,164 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/golibs/types"
+)
+
+// StatusReverted marks transactions of blocks orphaned by a chain reorganization
+const StatusReverted types.Status = "reverted"
+
+// handleReorg checks that the fetched blocks extend the stored chain. On a mismatch it publishes
+// the orphaned transactions as reverted, rewinds the tracker to the common ancestor and returns true.
+func handleReorg(params Params, blocks []Block) (bool, error) {
+	headerAPI, ok := params.Api.(blockatlas.BlockHeaderAPI)
+	if !ok || params.ReorgDepth <= 0 || len(blocks) == 0 {
+		return false, nil
+	}
+
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i].Number < blocks[j].Number
+	})
+	for i := 1; i < len(blocks); i++ {
+		prev, next := blocks[i-1].Header, blocks[i].Header
+		if prev == nil || next == nil || blocks[i].Number != blocks[i-1].Number+1 {
+			continue
+		}
+		if next.ParentHash != prev.Hash {
+			return false, fmt.Errorf("block %d does not extend block %d, chain changed during fetch", next.Number, prev.Number)
+		}
+	}
+
+	first := blocks[0]
+	if first.Header == nil {
+		return false, nil
+	}
+
+	coin := params.Api.Coin().Handle
+	stored, err := params.Database.GetParsedBlocks(coin, first.Number-params.ReorgDepth, first.Number)
+	if err != nil {
+		return false, err
+	}
+	if len(stored) == 0 {
+		return false, nil
+	}
+	adjacent := stored[0].Number == first.Number-1
+	if adjacent && stored[0].Hash == first.Header.ParentHash {
+		return false, nil
+	}
+	if !adjacent {
+		// the parent was handed off to the failed blocks retry, the nearest stored block is checked instead
+		log.WithFields(log.Fields{"coin": coin, "block": first.Number, "nearest": stored[0].Number}).Info("Parent block not stored, checking the nearest one")
+	}
+
+	ancestor, orphaned, err := findCommonAncestor(headerAPI, stored)
+	if err != nil {
+		return false, err
+	}
+	if !adjacent && len(orphaned) == 0 {
+		return false, nil
+	}
+
+	log.WithFields(log.Fields{
+		"coin":     coin,
+		"block":    first.Number,
+		"ancestor": ancestor,
+		"orphaned": len(orphaned),
+		"tags":     raven.Tags{{Key: "coin", Value: coin}},
+	}).Warn("Chain reorganization detected")
+
+	if !params.isLeader() {
+		return false, errors.New("lease lost before rollback")
+	}
+
+	reverted, err := revertedTransactions(orphaned)
+	if err != nil {
+		return false, err
+	}
+	messages, err := newOutboxMessages(params, batchReverted, ancestor+1, stored[0].Number, memo.FilterTransactions(reverted), params.revertedConfirmation())
+	if err != nil {
+		return false, err
+	}
+	if err := params.Database.RollbackParsedBlocks(coin, params.leaseHolder(), ancestor, messages); err != nil {
+		return false, err
+	}
+	metrics.IncReorgs(coin)
+	metrics.ObserveParsedHeight(coin, ancestor)
+
+	log.WithFields(log.Fields{
+		"coin":         coin,
+		"ancestor":     ancestor,
+		"transactions": len(reverted),
+	}).Info("Rolled back to common ancestor")
+
+	return true, nil
+}
+
+// findCommonAncestor walks the stored blocks from the newest and returns the first one still on chain,
+// along with the orphaned blocks above it. If none matches, the whole stored window is orphaned.
+func findCommonAncestor(api blockatlas.BlockHeaderAPI, stored []models.ParsedBlock) (int64, []models.ParsedBlock, error) {
+	for i, block := range stored {
+		_, header, err := api.GetBlockWithHeader(block.Number)
+		if err != nil {
+			return 0, nil, err
+		}
+		if header != nil && header.Hash == block.Hash {
+			return block.Number, stored[:i], nil
+		}
+	}
+	return stored[len(stored)-1].Number - 1, stored, nil
+}
+
+func revertedTransactions(blocks []models.ParsedBlock) (types.Txs, error) {
+	result := make(types.Txs, 0)
+	for _, block := range blocks {
+		if len(block.Txs) == 0 {
+			continue
+		}
+		var txs types.Txs
+		if err := json.Unmarshal(block.Txs, &txs); err != nil {
+			return nil, err
+		}
+		for _, tx := range txs {
+			tx.Status = StatusReverted
+			result = append(result, tx)
+		}
+	}
+	return result, nil
+}
+
+// parsedBlocks returns the hashes of the blocks to keep for the reorg detection, and the number the older ones
+// are pruned below
+func parsedBlocks(params Params, blocks []Block) ([]models.ParsedBlock, int64, error) {
+	if params.ReorgDepth <= 0 || len(blocks) == 0 {
+		return nil, 0, nil
+	}
+	parsed := make([]models.ParsedBlock, 0, len(blocks))
+	for _, block := range blocks {
+		if block.Header == nil {
+			continue
+		}
+		txs, err := json.Marshal(block.Txs)
+		if err != nil {
+			return nil, 0, err
+		}
+		parsed = append(parsed, models.ParsedBlock{
+			Coin:       params.Api.Coin().Handle,
+			Number:     block.Number,
+			Hash:       block.Header.Hash,
+			ParentHash: block.Header.ParentHash,
+			Txs:        txs,
+		})
+	}
+	return parsed, blocks[len(blocks)-1].Number - params.ReorgDepth, nil
+}
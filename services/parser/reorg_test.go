@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/golibs/types"
+)
+
+type headerPlatform struct {
+	Platform
+	hashes map[int64]string
+}
+
+func (p *headerPlatform) GetBlockWithHeader(num int64) (*types.Block, *blockatlas.BlockHeader, error) {
+	return &types.Block{Number: num}, &blockatlas.BlockHeader{Number: num, Hash: p.hashes[num]}, nil
+}
+
+func TestFindCommonAncestor(t *testing.T) {
+	api := &headerPlatform{hashes: map[int64]string{10: "a10", 11: "b11", 12: "b12"}}
+	stored := []models.ParsedBlock{
+		{Number: 12, Hash: "a12"},
+		{Number: 11, Hash: "a11"},
+		{Number: 10, Hash: "a10"},
+	}
+
+	ancestor, orphaned, err := findCommonAncestor(api, stored)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), ancestor)
+	assert.Equal(t, stored[:2], orphaned)
+
+	api.hashes[10] = "b10"
+	ancestor, orphaned, err = findCommonAncestor(api, stored)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(9), ancestor)
+	assert.Equal(t, stored, orphaned)
+}
+
+func TestRevertedTransactions(t *testing.T) {
+	raw, err := json.Marshal(types.Txs{
+		{ID: "1", Fee: "1", Status: types.StatusCompleted, Meta: types.Transfer{Value: "1"}},
+		{ID: "2", Fee: "1", Status: types.StatusCompleted, Meta: types.Transfer{Value: "2"}},
+	})
+	assert.Nil(t, err)
+
+	txs, err := revertedTransactions([]models.ParsedBlock{{Number: 11, Txs: raw}, {Number: 12}})
+	assert.Nil(t, err)
+	assert.Len(t, txs, 2)
+	for _, tx := range txs {
+		assert.Equal(t, StatusReverted, tx.Status)
+	}
+}
+
+func TestHandleReorg_InconsistentBatch(t *testing.T) {
+	params := Params{Api: &headerPlatform{}, ReorgDepth: 10}
+	blocks := []Block{
+		{Block: types.Block{Number: 11}, Header: &blockatlas.BlockHeader{Number: 11, Hash: "a11", ParentHash: "a10"}},
+		{Block: types.Block{Number: 12}, Header: &blockatlas.BlockHeader{Number: 12, Hash: "a12", ParentHash: "b11"}},
+	}
+	reorg, err := handleReorg(params, blocks)
+	assert.False(t, reorg)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/trustwallet/blockatlas/internal/metrics"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/golibs/types"
+)
+
+// Scheduler bounds the concurrency and the request rate of block fetching for one coin.
+// It is shared by every loop fetching blocks of that coin.
+type Scheduler struct {
+	concurrency int
+	interval    time.Duration
+	slots       chan struct{}
+	queued      int64
+	budget      *FetchBudget
+	priority    atomic.Value
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewScheduler creates a scheduler running at most concurrency fetches at once, and issuing at most
+// requestsPerSecond upstream requests, 0 means no rate limit
+func NewScheduler(concurrency int, requestsPerSecond float64) *Scheduler {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	var interval time.Duration
+	if requestsPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / requestsPerSecond)
+	}
+	return &Scheduler{
+		concurrency: concurrency,
+		interval:    interval,
+		slots:       make(chan struct{}, concurrency),
+	}
+}
+
+// QueueDepth returns the number of blocks waiting for a free fetch slot
+func (s *Scheduler) QueueDepth() int64 {
+	return atomic.LoadInt64(&s.queued)
+}
+
+func (s *Scheduler) Concurrency() int {
+	return s.concurrency
+}
+
+// UseBudget makes every fetch attempt also take a slot of the budget shared with the other coins
+func (s *Scheduler) UseBudget(budget *FetchBudget) {
+	s.budget = budget
+}
+
+// SetPriority sets the claim of the coin on the shared budget, it can change between parse steps
+func (s *Scheduler) SetPriority(priority string) {
+	s.priority.Store(priority)
+}
+
+func (s *Scheduler) getPriority() string {
+	priority, _ := s.priority.Load().(string)
+	return priority
+}
+
+// FetchBlocks fetches the blocks with a bounded pool of workers and returns them along with
+// the error of every height that failed after retries. A panic of a worker is raised again in the caller,
+// once the other workers are done.
+func (s *Scheduler) FetchBlocks(api blockatlas.BlockAPI, numbers []int64) ([]Block, map[int64]error) {
+	var (
+		jobs      = make(chan int64, len(numbers))
+		blocks    = make([]Block, 0, len(numbers))
+		failed    = make(map[int64]error)
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		workers   = s.concurrency
+		workPanic interface{}
+	)
+	atomic.AddInt64(&s.queued, int64(len(numbers)))
+	for _, number := range numbers {
+		jobs <- number
+	}
+	close(jobs)
+
+	if len(numbers) < workers {
+		workers = len(numbers)
+	}
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if value := recover(); value != nil {
+					mu.Lock()
+					workPanic = value
+					mu.Unlock()
+					for range jobs {
+						atomic.AddInt64(&s.queued, -1)
+					}
+				}
+			}()
+			for number := range jobs {
+				block, err := s.FetchBlock(api, number)
+				mu.Lock()
+				if err != nil {
+					failed[number] = err
+				} else {
+					blocks = append(blocks, block)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if workPanic != nil {
+		panic(workPanic)
+	}
+
+	return blocks, failed
+}
+
+// FetchBlock takes a fetch slot and fetches the block with retries, each attempt is rate limited.
+// A panic while fetching or normalizing the block is raised again as a *BlockPanic.
+func (s *Scheduler) FetchBlock(api blockatlas.BlockAPI, num int64) (Block, error) {
+	s.slots <- struct{}{}
+	atomic.AddInt64(&s.queued, -1)
+	defer func() { <-s.slots }()
+	defer func() {
+		if value := recover(); value != nil {
+			if _, ok := value.(*BlockPanic); ok {
+				panic(value)
+			}
+			panic(&BlockPanic{Number: num, Value: value, Stack: debug.Stack()})
+		}
+	}()
+
+	var header *blockatlas.BlockHeader
+	getBlock := func(num int64) (*types.Block, error) {
+		var (
+			block *types.Block
+			err   error
+		)
+		block, header, err = s.fetch(api, num)
+		return block, err
+	}
+	block, err := getBlockByNumberWithRetry(5, time.Second*5, getBlock, num, api.Coin().Symbol)
+	if err != nil {
+		return Block{}, err
+	}
+	if block == nil {
+		return Block{}, fmt.Errorf("empty block %d", num)
+	}
+	return Block{Block: *block, Header: header}, nil
+}
+
+// fetch makes one rate limited attempt within the budget, along with the header if the platform provides one
+func (s *Scheduler) fetch(api blockatlas.BlockAPI, num int64) (*types.Block, *blockatlas.BlockHeader, error) {
+	s.wait()
+	s.budget.acquire(s.getPriority())
+	defer s.budget.release()
+
+	var (
+		block  *types.Block
+		header *blockatlas.BlockHeader
+		err    error
+		start  = time.Now()
+	)
+	if headerAPI, ok := api.(blockatlas.BlockHeaderAPI); ok {
+		block, header, err = headerAPI.GetBlockWithHeader(num)
+	} else {
+		block, err = api.GetBlockByNumber(num)
+	}
+	metrics.ObserveFetch(api.Coin().Handle, time.Since(start).Seconds(), err)
+	return block, header, err
+}
+
+func (s *Scheduler) wait() {
+	if s.interval <= 0 {
+		return
+	}
+	s.mu.Lock()
+	now := time.Now()
+	if s.next.Before(now) {
+		s.next = now
+	}
+	delay := s.next.Sub(now)
+	s.next = s.next.Add(s.interval)
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/types"
+)
+
+type concurrencyPlatform struct {
+	Platform
+	active, max int32
+}
+
+func (p *concurrencyPlatform) GetBlockByNumber(num int64) (*types.Block, error) {
+	active := atomic.AddInt32(&p.active, 1)
+	defer atomic.AddInt32(&p.active, -1)
+	for {
+		max := atomic.LoadInt32(&p.max)
+		if active <= max || atomic.CompareAndSwapInt32(&p.max, max, active) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond * 2)
+	return &types.Block{Number: num}, nil
+}
+
+func TestScheduler_FetchBlocks(t *testing.T) {
+	api := &concurrencyPlatform{Platform: Platform{CoinIndex: 60}}
+	scheduler := NewScheduler(3, 0)
+
+	blocks, failed := scheduler.FetchBlocks(api, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10})
+	assert.Len(t, blocks, 10)
+	assert.Empty(t, failed)
+	assert.LessOrEqual(t, atomic.LoadInt32(&api.max), int32(3))
+	assert.Equal(t, int64(0), scheduler.QueueDepth())
+}
+
+func TestScheduler_RateLimit(t *testing.T) {
+	scheduler := NewScheduler(10, 200)
+
+	now := time.Now()
+	blocks, _ := scheduler.FetchBlocks(getMockedBlockAPI(), []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11})
+	assert.Len(t, blocks, 11)
+	assert.GreaterOrEqual(t, int64(time.Since(now)), int64(time.Millisecond*50))
+}
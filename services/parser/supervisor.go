@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/internal/metrics"
+)
+
+const (
+	defaultMinRestartBackoff = time.Second
+	defaultMaxRestartBackoff = time.Minute * 5
+)
+
+// BlockPanic is raised again in the parsing goroutine when the fetch or the normalization of a block panicked
+type BlockPanic struct {
+	Number int64
+	Value  interface{}
+	Stack  []byte
+}
+
+func (p *BlockPanic) Error() string {
+	return fmt.Sprintf("panic on block %d: %v", p.Number, p.Value)
+}
+
+// Supervisor runs the loops of every coin, recovers their panics and restarts them with exponential backoff,
+// so a coin failing on an unexpected response doesn't stop the others
+type Supervisor struct {
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	wg         sync.WaitGroup
+}
+
+// NewSupervisor creates a supervisor restarting a loop after minBackoff, doubled on every panic up to maxBackoff.
+// The backoff is reset once a loop has run for maxBackoff without panicking.
+func NewSupervisor(minBackoff, maxBackoff time.Duration) *Supervisor {
+	if minBackoff <= 0 {
+		minBackoff = defaultMinRestartBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = defaultMaxRestartBackoff
+	}
+	return &Supervisor{minBackoff: minBackoff, maxBackoff: maxBackoff}
+}
+
+// Go runs the loop of the coin in a goroutine until ctx is done, restarting it whenever it panics
+func (s *Supervisor) Go(ctx context.Context, coin, loop string, run func(ctx context.Context)) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		backoff := s.minBackoff
+		for {
+			start := time.Now()
+			stable := time.AfterFunc(s.maxBackoff, func() {
+				metrics.SetDegraded(coin, loop, false)
+			})
+			recovered := runRecovered(ctx, coin, loop, run)
+			stable.Stop()
+			if !recovered || ctx.Err() != nil {
+				return
+			}
+
+			metrics.IncPanics(coin, loop)
+			metrics.SetDegraded(coin, loop, true)
+			if time.Since(start) >= s.maxBackoff {
+				backoff = s.minBackoff
+			}
+			log.WithFields(log.Fields{"coin": coin, "loop": loop, "backoff": backoff}).Warn("Restarting after panic")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+		}
+	}()
+}
+
+// Wait blocks until every loop returned, or the timeout elapsed. It reports whether all loops stopped.
+func (s *Supervisor) Wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// runRecovered runs the loop once and reports whether it ended with a panic
+func runRecovered(ctx context.Context, coin, loop string, run func(ctx context.Context)) (panicked bool) {
+	defer func() {
+		value := recover()
+		if value == nil {
+			return
+		}
+		panicked = true
+
+		fields := log.Fields{"coin": coin, "loop": loop, "panic": value}
+		tags := raven.Tags{{Key: "coin", Value: coin}, {Key: "loop", Value: loop}}
+		if blockPanic, ok := value.(*BlockPanic); ok {
+			fields["block"] = blockPanic.Number
+			fields["panic"] = blockPanic.Value
+			fields["stack"] = string(blockPanic.Stack)
+			tags = append(tags, raven.Tag{Key: "block", Value: strconv.FormatInt(blockPanic.Number, 10)})
+		} else {
+			fields["stack"] = string(debug.Stack())
+		}
+		fields["tags"] = tags
+		log.WithFields(fields).Error("Parser panic")
+	}()
+	run(ctx)
+	return false
+}
@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/golibs/types"
+)
+
+type panicPlatform struct {
+	Platform
+}
+
+func (p *panicPlatform) GetBlockByNumber(num int64) (*types.Block, error) {
+	if num == 3 {
+		var normalized map[string]string
+		normalized["tx"] = "1"
+	}
+	return &types.Block{Number: num}, nil
+}
+
+func TestScheduler_FetchBlocksPanic(t *testing.T) {
+	scheduler := NewScheduler(2, 0)
+
+	defer func() {
+		blockPanic, ok := recover().(*BlockPanic)
+		assert.True(t, ok)
+		assert.Equal(t, int64(3), blockPanic.Number)
+		assert.NotEmpty(t, blockPanic.Stack)
+		assert.Equal(t, int64(0), scheduler.QueueDepth())
+	}()
+	scheduler.FetchBlocks(&panicPlatform{Platform: Platform{CoinIndex: 60}}, []int64{1, 2, 3, 4, 5})
+	t.Error("expected a panic")
+}
+
+func TestSupervisor_Restart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	supervisor := NewSupervisor(time.Millisecond, time.Millisecond*10)
+
+	var runs int32
+	supervisor.Go(ctx, "ethereum", "parser", func(ctx context.Context) {
+		if atomic.AddInt32(&runs, 1) < 3 {
+			panic(&BlockPanic{Number: 10, Value: "nil map"})
+		}
+		<-ctx.Done()
+	})
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&runs) == 3
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	assert.True(t, supervisor.Wait(time.Second))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&runs))
+}
+
+func TestSupervisor_WaitTimeout(t *testing.T) {
+	supervisor := NewSupervisor(0, 0)
+	stop := make(chan struct{})
+	supervisor.Go(context.Background(), "ethereum", "parser", func(ctx context.Context) {
+		<-stop
+	})
+
+	assert.False(t, supervisor.Wait(time.Millisecond*10))
+	close(stop)
+	assert.True(t, supervisor.Wait(time.Second))
+}
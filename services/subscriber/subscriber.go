@@ -8,40 +8,92 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
 	"github.com/trustwallet/golibs/types"
 )
 
+// Event is a types.SubscriptionEvent, optionally carrying the notification rules of the added subscriptions
+// and the wallet subscribing or unsubscribing them
+type Event struct {
+	types.SubscriptionEvent
+	Rules *models.SubscriptionRules `json:"rules,omitempty"`
+	// Wallet references the subscriptions, they are deleted once no wallet references them anymore.
+	// Subscriptions added without a wallet are not referenced, an event without a wallet only deletes the
+	// subscriptions no wallet references.
+	Wallet string `json:"wallet,omitempty"`
+}
+
 func RunSubscriber(database *db.Instance, delivery amqp.Delivery) error {
-	var event types.SubscriptionEvent
+	var event Event
 	err := json.Unmarshal(delivery.Body, &event)
 	if err != nil {
 		log.WithFields(log.Fields{"service": types.Notifications, "body": string(delivery.Body), "error": err}).Error("Unable to unmarshal MQ Message")
-		return nil
+		return internal.Permanent(err)
 	}
 
-	subscriptions := event.ParseSubscriptions(event.Subscriptions)
+	subscriptions, xpubs := SplitXpubs(canonical.Subscriptions(event.ParseSubscriptions(event.Subscriptions)))
 	switch event.Operation {
 	case types.AddSubscription:
-		err := database.CreateSubscriptions(subscriptions)
+		err := database.CreateWalletSubscriptions(event.Wallet, subscriptions)
 		if err != nil {
-			log.WithFields(log.Fields{"service": types.Notifications, "operation": event.Operation, "subscriptions": subscriptions}).Error(err)
+			log.WithFields(log.Fields{"service": types.Notifications, "operation": event.Operation, "subscriptions": subscriptions, "wallet": event.Wallet}).Error(err)
+			return err
+		}
+		if err := addXpubs(database, xpubs, event.Wallet); err != nil {
+			log.WithFields(log.Fields{"service": types.Notifications, "operation": event.Operation, "xpubs": len(xpubs)}).Error(err)
 			return err
 		}
+		if event.Rules != nil {
+			if err := database.SetSubscriptionRules(addressIDs(subscriptions), *event.Rules); err != nil {
+				log.WithFields(log.Fields{"service": types.Notifications, "operation": event.Operation, "subscriptions": subscriptions}).Error(err)
+				return err
+			}
+		}
 		log.WithFields(log.Fields{"service": types.Notifications, "operation": event.Operation, "subscriptions": len(subscriptions)}).Info("Add subscriptions")
 	case types.DeleteSubscription:
-		subscriptionsIds := make([]string, 0)
-		for _, subscription := range subscriptions {
-			subscriptionsIds = append(subscriptionsIds, subscription.AddressID())
+		if event.Wallet == "" {
+			deleted, err := database.DeleteUnreferencedSubscriptions(addressIDs(subscriptions))
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{"service": types.Notifications, "subscriptions": len(subscriptions), "deleted": len(deleted)}).Info("Delete subscriptions")
+		} else {
+			deleted, err := database.UnsubscribeWallet(event.Wallet, addressIDs(subscriptions))
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{"service": types.Notifications, "wallet": event.Wallet, "subscriptions": len(subscriptions), "deleted": len(deleted)}).Info("Unsubscribe wallet")
+		}
+		if err := deleteXpubs(database, xpubs, event.Wallet); err != nil {
+			return err
 		}
-		return database.DeleteSubscriptions(subscriptionsIds)
+		PublishUpdate(delivery.Body)
+		return nil
 	}
+	PublishUpdate(delivery.Body)
 
 	// Pass over subscribed addresses to find all associated tokens to such addresses
 	err = internal.SubscriptionsTokens.Publish(delivery.Body)
 	if err != nil {
 		log.Error(err)
-		return nil
+		return err
 	}
 
 	return nil
 }
+
+// PublishUpdate lets the notifiers follow the subscriptions without querying them
+func PublishUpdate(body []byte) {
+	if err := internal.SubscriptionsUpdates.Publish(body); err != nil {
+		log.WithFields(log.Fields{"service": types.Notifications, "error": err}).Error("Unable to publish subscriptions update")
+	}
+}
+
+func addressIDs(subscriptions []types.Subscription) []string {
+	result := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		result = append(result, subscription.AddressID())
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package subscriber
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/golibs/types"
+)
+
+const defaultXpubGapLimit = 20
+
+var (
+	xpubAPIs     = map[uint]blockatlas.XpubAPI{}
+	xpubGapLimit = defaultXpubGapLimit
+	xpubPrefixes = []string{"xpub", "ypub", "zpub"}
+)
+
+// SetupXpubs lets RunSubscriber subscribe the extended public keys of the coins, through their derived addresses
+func SetupXpubs(apis map[uint]blockatlas.XpubAPI, gapLimit int) {
+	xpubAPIs = apis
+	if gapLimit > 0 {
+		xpubGapLimit = gapLimit
+	}
+}
+
+func isXpub(address string) bool {
+	for _, prefix := range xpubPrefixes {
+		if strings.HasPrefix(address, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// SplitXpubs separates the extended public keys from the addresses
+func SplitXpubs(subscriptions []types.Subscription) ([]types.Subscription, []types.Subscription) {
+	addresses := make([]types.Subscription, 0, len(subscriptions))
+	xpubs := make([]types.Subscription, 0)
+	for _, subscription := range subscriptions {
+		if isXpub(subscription.Address) {
+			xpubs = append(xpubs, subscription)
+			continue
+		}
+		addresses = append(addresses, subscription)
+	}
+	return addresses, xpubs
+}
+
+// addXpubs subscribes the addresses derived from the xpubs. Adding an xpub again extends its derived set,
+// the notifier does it once one of its unused addresses gets used.
+func addXpubs(database *db.Instance, xpubs []types.Subscription, wallet string) error {
+	for _, xpub := range xpubs {
+		api, ok := xpubAPIs[xpub.Coin]
+		if !ok {
+			log.WithFields(log.Fields{"service": types.Notifications, "coin": xpub.Coin}).Warn("Xpub subscriptions are not supported by the coin")
+			continue
+		}
+		derived, err := api.GetXpubAddresses(xpub.Address, xpubGapLimit)
+		if err != nil {
+			return err
+		}
+
+		coin := strconv.Itoa(int(xpub.Coin))
+		addresses := make([]models.XpubAddress, 0, len(derived))
+		subscribed := make([]string, 0, len(derived))
+		for _, address := range derived {
+			addresses = append(addresses, models.XpubAddress{
+				Address: canonical.AddressID(coin, address.Address),
+				Change:  address.Change,
+				Index:   address.Index,
+				Used:    address.Used,
+			})
+			subscribed = append(subscribed, canonical.Address(xpub.Coin, address.Address))
+		}
+		if err := database.CreateXpubAddresses(xpub.Coin, xpub.Address, addresses); err != nil {
+			return err
+		}
+		if wallet != "" {
+			ids := make([]string, 0, len(addresses))
+			for _, address := range addresses {
+				ids = append(ids, address.Address)
+			}
+			if err := database.SubscribeWallet(wallet, ids); err != nil {
+				return err
+			}
+		}
+		log.WithFields(log.Fields{"service": types.Notifications, "coin": xpub.Coin, "addresses": len(addresses)}).Info("Add xpub subscription")
+
+		body, err := json.Marshal(types.SubscriptionEvent{
+			Operation:     types.AddSubscription,
+			Subscriptions: types.Subscriptions{coin: subscribed},
+		})
+		if err != nil {
+			return err
+		}
+		PublishUpdate(body)
+	}
+	return nil
+}
+
+// deleteXpubs unsubscribes the xpubs, for a wallet only once no other wallet references them
+func deleteXpubs(database *db.Instance, xpubs []types.Subscription, wallet string) error {
+	for _, xpub := range xpubs {
+		if wallet != "" {
+			referenced, err := database.UnsubscribeXpubWallet(xpub.Coin, xpub.Address, wallet)
+			if err != nil {
+				return err
+			}
+			if referenced {
+				continue
+			}
+		}
+		if err := database.DeleteXpub(xpub.Coin, xpub.Address); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RefreshXpubs asks the subscriber to extend the derived addresses of the xpubs
+func RefreshXpubs(xpubs []models.Xpub) error {
+	subscriptions := make(types.Subscriptions)
+	for _, xpub := range xpubs {
+		coin := strconv.Itoa(int(xpub.Coin))
+		subscriptions[coin] = append(subscriptions[coin], xpub.Key)
+	}
+	body, err := json.Marshal(types.SubscriptionEvent{Operation: types.AddSubscription, Subscriptions: subscriptions})
+	if err != nil {
+		return err
+	}
+	return internal.Subscriptions.Publish(body)
+}
+
+// QueueXpubs hands the xpubs of the wallet over to RunSubscriber, which derives their addresses
+func QueueXpubs(operation types.SubscriptionOperation, xpubs []types.Subscription, wallet string) error {
+	if len(xpubs) == 0 {
+		return nil
+	}
+	subscriptions := make(types.Subscriptions)
+	for _, xpub := range xpubs {
+		coin := strconv.Itoa(int(xpub.Coin))
+		subscriptions[coin] = append(subscriptions[coin], xpub.Address)
+	}
+	body, err := json.Marshal(Event{
+		SubscriptionEvent: types.SubscriptionEvent{Operation: operation, Subscriptions: subscriptions},
+		Wallet:            wallet,
+	})
+	if err != nil {
+		return err
+	}
+	return internal.Subscriptions.Publish(body)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/trustwallet/blockatlas/db"
 	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
 	"github.com/trustwallet/golibs/types"
 )
 
@@ -32,7 +33,7 @@ func (i Instance) GetTokensByAddress(r GetTokensByAddressRequest) (GetTokensByAd
 
 	for coin, coins := range r.AddressesByCoin {
 		for _, address := range coins {
-			list = append(list, types.GetAddressID(coin, address))
+			list = append(list, canonical.AddressID(coin, address))
 		}
 	}
 	from := time.Unix(int64(r.From), 0)
@@ -43,7 +44,14 @@ func (i Instance) GetTokensByAddress(r GetTokensByAddressRequest) (GetTokensByAd
 
 	assetIds := make([]GetTokensAsset, 0)
 
+	// each memo subscribed on an address holds the tokens of the address
+	seen := make(map[string]bool)
 	for _, association := range associations {
+		key := association.Subscription.Address + "_" + association.Asset.Asset
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
 		assetIds = append(assetIds, GetTokensAsset{
 			AssetId:   association.Asset.Asset,
 			CreatedAt: association.CreatedAt.Unix(),
@@ -7,6 +7,8 @@ import (
 	"github.com/streadway/amqp"
 	"github.com/trustwallet/blockatlas/db"
 	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
 	"github.com/trustwallet/blockatlas/services/notifier"
 	"github.com/trustwallet/golibs/types"
 )
@@ -20,7 +22,7 @@ func RunTokenIndexer(database *db.Instance, delivery amqp.Delivery) error {
 	transactions, err := notifier.GetTransactionsFromDelivery(delivery, TokenIndexer)
 	if err != nil {
 		log.WithFields(log.Fields{"service": TokenIndexer, "body": string(delivery.Body), "error": err}).Error("Unable to unmarshal MQ Message")
-		return nil
+		return internal.Permanent(err)
 	}
 
 	assetsTxs := transactions.FilterTransactionsByType([]types.TransactionType{
@@ -85,31 +87,29 @@ func calculateSubscriptionAssetAssociations(database *db.Instance, addressAssets
 		assetsMap[asset.Asset] = asset
 	}
 
-	subscriptionsMap := map[string]models.Subscription{}
+	// the tokens are held by the address, every memo subscribed on it gets them
+	subscriptionsMap := map[string][]models.Subscription{}
 	for _, subscription := range subscriptions {
-		subscriptionsMap[subscription.Address] = subscription
+		subscriptionsMap[subscription.Address] = append(subscriptionsMap[subscription.Address], subscription)
 	}
 
 	uniqueMap := map[string]bool{}
 	for addressId, assets := range addressAssetsMap {
-		subscription, ok := subscriptionsMap[addressId]
-		if !ok {
-			continue
-		}
-
-		for _, assetId := range assets {
-			asset, ok := assetsMap[assetId]
-			if !ok {
-				continue
-			}
-			subscriptionKey := strconv.Itoa(int(asset.ID)) + "_" + strconv.Itoa(int(subscription.ID))
-			if _, ok := uniqueMap[subscriptionKey]; !ok {
-				association := models.SubscriptionsAssetAssociation{
-					SubscriptionId: subscription.ID,
-					AssetId:        asset.ID,
+		for _, subscription := range subscriptionsMap[addressId] {
+			for _, assetId := range assets {
+				asset, ok := assetsMap[assetId]
+				if !ok {
+					continue
+				}
+				subscriptionKey := strconv.Itoa(int(asset.ID)) + "_" + strconv.Itoa(int(subscription.ID))
+				if _, ok := uniqueMap[subscriptionKey]; !ok {
+					association := models.SubscriptionsAssetAssociation{
+						SubscriptionId: subscription.ID,
+						AssetId:        asset.ID,
+					}
+					associations = append(associations, association)
+					uniqueMap[subscriptionKey] = true
 				}
-				associations = append(associations, association)
-				uniqueMap[subscriptionKey] = true
 			}
 		}
 	}
@@ -129,13 +129,13 @@ func GetAssetsFromTransactions(txs types.Txs) []models.Asset {
 func assetsMap(txs types.Txs) map[string][]string {
 	result := make(map[string][]string)
 	for _, tx := range txs {
-		prefix := strconv.Itoa(int(tx.Coin)) + "_"
+		coin := strconv.Itoa(int(tx.Coin))
 		addresses := tx.GetAddresses()
 		assets := models.AssetsFrom(tx)
 
 		for _, asset := range assets {
 			for _, address := range addresses {
-				assetId := prefix + address
+				assetId := canonical.AddressID(coin, address)
 				assetIDs := result[assetId]
 				result[assetId] = append(assetIDs, asset.Asset)
 			}
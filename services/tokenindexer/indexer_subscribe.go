@@ -2,11 +2,15 @@ package tokenindexer
 
 import (
 	"encoding/json"
+	"strconv"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/streadway/amqp"
 	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/internal"
 	"github.com/trustwallet/blockatlas/pkg/blockatlas"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/blockatlas/pkg/memo"
 	"github.com/trustwallet/golibs/types"
 )
 
@@ -26,12 +30,12 @@ func RunTokenIndexerSubscribe(database *db.Instance, apis map[uint]blockatlas.To
 	err := json.Unmarshal(delivery.Body, &event)
 	if err != nil {
 		log.WithFields(log.Fields{"service": SubscriptionsTokenIndexer, "body": string(delivery.Body), "error": err}).Error("Unable to unmarshal MQ Message")
-		return nil
+		return internal.Permanent(err)
 	}
 
 	log.WithFields(log.Fields{"service": TokenIndexer, "event": event.Operation, "subscriptions": len(event.Subscriptions)}).Info("Processing")
 
-	subscriptions := event.ParseSubscriptions(event.Subscriptions)
+	subscriptions := canonical.Subscriptions(event.ParseSubscriptions(event.Subscriptions))
 	switch event.Operation {
 	case types.AddSubscription:
 		addressAssetsMap := map[string][]string{}
@@ -41,11 +45,12 @@ func RunTokenIndexerSubscribe(database *db.Instance, apis map[uint]blockatlas.To
 			if !ok {
 				continue
 			}
-			assetIds, err := api.GetTokenListIdsByAddress(coinAddress.Address)
+			address, _ := memo.Split(coinAddress.Coin, coinAddress.Address)
+			assetIds, err := api.GetTokenListIdsByAddress(address)
 			if err != nil {
 				continue
 			}
-			addressAssetsMap[coinAddress.AddressID()] = assetIds
+			addressAssetsMap[canonical.AddressID(strconv.Itoa(int(coinAddress.Coin)), address)] = assetIds
 		}
 		return CreateAssociations(database, addressAssetsMap)
 	case types.DeleteSubscription:
@@ -0,0 +1,412 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/streadway/amqp"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/pkg/canonical"
+	"github.com/trustwallet/blockatlas/pkg/memo"
+	"github.com/trustwallet/blockatlas/services/notifier"
+	"github.com/trustwallet/golibs/types"
+)
+
+const (
+	Webhooks = "Webhooks"
+
+	SignatureHeader = "X-Blockatlas-Signature"
+	DeliveryHeader  = "X-Blockatlas-Delivery"
+
+	defaultTimeout     = time.Second * 10
+	defaultMaxAttempts = 8
+	defaultBackoff     = time.Second * 5
+	defaultMaxBackoff  = time.Hour
+	defaultInterval    = time.Second
+	sendBatchSize      = 100
+)
+
+type Params struct {
+	Database    *db.Instance
+	Client      *http.Client
+	MaxAttempts int
+	// Backoff is the delay before the second attempt, doubled after every failed attempt up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// RetryInterval is how often the due attempts are polled, the first ones included
+	RetryInterval time.Duration
+}
+
+// Consumer delivers the notifications of the txNotifications queue to the webhooks of their subscriptions
+type Consumer struct {
+	Params Params
+	Tag    string
+}
+
+// Networks are the address ranges the operator lets webhooks reach although they are not public
+type Networks []*net.IPNet
+
+// privateNetworks are rejected on top of the loopback, link-local and unspecified addresses: the RFC 1918 and
+// unique local ranges, and the carrier-grade NAT one. net.IP.IsPrivate needs go1.17.
+var privateNetworks = mustParseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7")
+
+// ParseNetworks parses the CIDRs of the webhooks.allowed_networks config key
+func ParseNetworks(cidrs []string) (Networks, error) {
+	networks := make(Networks, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func mustParseNetworks(cidrs ...string) Networks {
+	networks, err := ParseNetworks(cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return networks
+}
+
+func (n Networks) Contains(ip net.IP) bool {
+	for _, network := range n {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewClient creates the client the webhooks are POSTed with, 0 means the default timeout. It refuses to connect
+// to loopback, link-local and private addresses outside the allowed networks, whatever the webhook host resolves to.
+func NewClient(timeout time.Duration, allowed Networks) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout, Control: controlAddress(allowed)}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// ValidateURL checks a webhook URL is an absolute http or https URL whose host isn't loopback, link-local or
+// private, unless it is in the allowed networks
+func ValidateURL(raw string, allowed Networks) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("webhook url must be an absolute http or https url")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return errors.New("webhook url has no host")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("webhook host %s is not allowed", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !allowedIP(ip, allowed) {
+		return fmt.Errorf("webhook host %s is not allowed", host)
+	}
+	return nil
+}
+
+func allowedIP(ip net.IP, allowed Networks) bool {
+	if allowed.Contains(ip) {
+		return true
+	}
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsUnspecified() &&
+		!privateNetworks.Contains(ip)
+}
+
+// controlAddress rejects the connections to the addresses ValidateURL rejects, once the host is resolved
+func controlAddress(allowed Networks) func(network, address string, _ syscall.RawConn) error {
+	return func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !allowedIP(ip, allowed) {
+			return fmt.Errorf("webhook address %s is not allowed", host)
+		}
+		return nil
+	}
+}
+
+func (p Params) withDefaults() Params {
+	if p.Client == nil {
+		p.Client = NewClient(0, nil)
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultMaxAttempts
+	}
+	if p.Backoff <= 0 {
+		p.Backoff = defaultBackoff
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = defaultMaxBackoff
+	}
+	if p.RetryInterval <= 0 {
+		p.RetryInterval = defaultInterval
+	}
+	return p
+}
+
+// Callback stores a delivery per notification and webhook, due at once. The attempts are left to RunDeliveries,
+// so a slow webhook doesn't hold the queue. Deliveries are at least once, receivers dedupe on DeliveryHeader.
+func (c Consumer) Callback(msg amqp.Delivery) error {
+	params := c.Params.withDefaults()
+
+	var notifications []notifier.Notification
+	if err := json.Unmarshal(msg.Body, &notifications); err != nil {
+		log.WithFields(log.Fields{"service": Webhooks, "body": string(msg.Body), "error": err}).Error("Unable to unmarshal MQ Message")
+		return internal.Permanent(err)
+	}
+
+	deliveries, err := newDeliveries(params.Database, notifications, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := params.Database.CreateWebhookDeliveries(deliveries); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{"service": Webhooks, "notifications": len(notifications), "deliveries": len(deliveries)}).Info("Consumed")
+	return nil
+}
+
+// RunDeliveries makes the due attempts of the deliveries until ctx is done. Every replica may run it, a delivery
+// is claimed until its attempt timed out and the backoff elapsed, so it is attempted by one replica at a time.
+func RunDeliveries(params Params, ctx context.Context) {
+	params = params.withDefaults()
+	ticker := time.NewTicker(params.RetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for ctx.Err() == nil && sendDue(params) == sendBatchSize {
+				log.WithFields(log.Fields{"service": Webhooks}).Info("More webhook deliveries due")
+			}
+		}
+	}
+}
+
+// claimLease is how long a claimed delivery stays hidden from the other replicas, long enough for its attempt to
+// time out and the first backoff to elapse
+func (p Params) claimLease() time.Duration {
+	timeout := p.Client.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return timeout + p.Backoff
+}
+
+// sendDue claims a batch of due deliveries and attempts them concurrently, it returns the size of the batch
+func sendDue(params Params) int {
+	deliveries, err := params.Database.ClaimDueWebhookDeliveries(time.Now(), params.claimLease(), sendBatchSize)
+	if err != nil {
+		log.WithFields(log.Fields{"service": Webhooks, "error": err}).Error("Unable to claim webhook deliveries")
+		return 0
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery models.WebhookDelivery) {
+			defer wg.Done()
+			deliver(params, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+// newDeliveries matches every notification with the webhooks of the subscription it was built for, the first
+// attempt due at nextAttempt
+func newDeliveries(database *db.Instance, notifications []notifier.Notification, nextAttempt time.Time) ([]models.WebhookDelivery, error) {
+	addressIDs := make([]string, 0)
+	for _, notification := range notifications {
+		coin := strconv.Itoa(int(notification.Result.Coin))
+		for _, address := range notification.Result.GetAddresses() {
+			addressIDs = append(addressIDs, canonical.AddressID(coin, address))
+		}
+	}
+	if len(addressIDs) == 0 {
+		return nil, nil
+	}
+
+	subscriptions, err := database.GetSubscriptions(addressIDs)
+	if err != nil {
+		return nil, err
+	}
+	subscriptionIDs := make([]uint, 0, len(subscriptions))
+	subscriptionsByAddress := make(map[string]uint, len(subscriptions))
+	for _, subscription := range subscriptions {
+		subscriptionIDs = append(subscriptionIDs, subscription.ID)
+		subscriptionsByAddress[subscription.Key()] = subscription.ID
+	}
+	webhooks, err := database.GetWebhooksBySubscriptionIDs(subscriptionIDs)
+	if err != nil {
+		return nil, err
+	}
+	webhooksBySubscription := make(map[uint][]models.Webhook)
+	for _, webhook := range webhooks {
+		webhooksBySubscription[webhook.SubscriptionID] = append(webhooksBySubscription[webhook.SubscriptionID], webhook)
+	}
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for i := range notifications {
+		payload, err := json.Marshal(&notifications[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, subscriptionID := range matchSubscriptions(notifications[i].TransactionNotification, subscriptionsByAddress) {
+			for _, webhook := range webhooksBySubscription[subscriptionID] {
+				deliveries = append(deliveries, models.WebhookDelivery{
+					Webhook:       webhook,
+					WebhookID:     webhook.ID,
+					Status:        models.WebhookDeliveryPending,
+					NextAttemptAt: nextAttempt,
+					Payload:       payload,
+				})
+			}
+		}
+	}
+	return deliveries, nil
+}
+
+// matchSubscriptions returns the subscriptions the notification was built for. The notifier builds one
+// notification per subscribed address, with the direction seen from that address. Subscriptions scoped to
+// a memo only match the notifications carrying it.
+func matchSubscriptions(notification types.TransactionNotification, subscriptionsByAddress map[string]uint) []uint {
+	coin := strconv.Itoa(int(notification.Result.Coin))
+	scope := memo.Normalize(notification.Result.Coin, notification.Result.Memo)
+	seen := make(map[uint]bool)
+	result := make([]uint, 0)
+	for _, address := range notification.Result.GetAddresses() {
+		tx := notification.Result
+		tx.Direction = ""
+		if notification.Result.Direction != "" && tx.GetTransactionDirection(address) != notification.Result.Direction {
+			continue
+		}
+		addressID := canonical.AddressID(coin, address)
+		for _, key := range []string{addressID, memo.Join(addressID, scope)} {
+			id, ok := subscriptionsByAddress[key]
+			if !ok || seen[id] {
+				continue
+			}
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+func deliver(params Params, delivery models.WebhookDelivery) {
+	statusCode, err := send(params.Client, delivery.Webhook, delivery.ID, delivery.Payload)
+	delivery = attempted(params, delivery, statusCode, err, time.Now())
+
+	fields := log.Fields{"service": Webhooks, "delivery": delivery.ID, "webhook": delivery.WebhookID, "attempts": delivery.Attempts}
+	if delivery.Status == models.WebhookDeliveryFailed {
+		fields["error"] = delivery.LastError
+		fields["tags"] = raven.Tags{{Key: "webhook", Value: strconv.Itoa(int(delivery.WebhookID))}}
+		log.WithFields(fields).Error("Webhook delivery parked after all attempts")
+		if err := params.Database.ParkWebhookDelivery(delivery); err != nil {
+			log.WithFields(log.Fields{"service": Webhooks, "delivery": delivery.ID, "error": err}).Error("Unable to park webhook delivery")
+		}
+		return
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		fields["error"] = delivery.LastError
+		fields["next attempt"] = delivery.NextAttemptAt
+		log.WithFields(fields).Warn("Webhook delivery failed")
+	}
+	if err := params.Database.SaveWebhookDeliveryAttempt(delivery); err != nil {
+		log.WithFields(log.Fields{"service": Webhooks, "delivery": delivery.ID, "error": err}).Error("Unable to save webhook delivery")
+	}
+}
+
+// attempted applies the outcome of an attempt, a failed delivery is retried with exponential backoff
+// until it runs out of attempts
+func attempted(params Params, delivery models.WebhookDelivery, statusCode int, err error, now time.Time) models.WebhookDelivery {
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.LastError = ""
+		return delivery
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= params.MaxAttempts {
+		delivery.Status = models.WebhookDeliveryFailed
+		return delivery
+	}
+	backoff := params.Backoff
+	for i := 1; i < delivery.Attempts && backoff < params.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > params.MaxBackoff {
+		backoff = params.MaxBackoff
+	}
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = now.Add(backoff)
+	return delivery
+}
+
+// send POSTs the payload signed with the webhook secret, any status but 2xx is an error
+func send(client *http.Client, webhook models.Webhook, deliveryID uint64, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(deliveryID, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, payload))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// Sign returns the signature of the payload sent in SignatureHeader, the hex HMAC-SHA256 with the webhook secret
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
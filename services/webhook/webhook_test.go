@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/golibs/coin"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestSend(t *testing.T) {
+	var (
+		body      []byte
+		signature string
+		delivery  string
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		delivery = r.Header.Get(DeliveryHeader)
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	payload := []byte(`{"action":"transfer"}`)
+	statusCode, err := send(receiver.Client(), models.Webhook{URL: receiver.URL, Secret: "secret"}, 7, payload)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, "7", delivery)
+	assert.Equal(t, "sha256=2806ef6989086702c65a5260f635b8a111242e5bddb6569ede88b603a24c60a0", signature)
+
+	statusCode, err = send(receiver.Client(), models.Webhook{URL: receiver.URL + "/down", Secret: "secret"}, 8, payload)
+	assert.NotNil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+}
+
+func TestAttempted(t *testing.T) {
+	params := Params{MaxAttempts: 4, Backoff: time.Second, MaxBackoff: time.Second * 3}.withDefaults()
+	now := time.Unix(1000, 0)
+	delivery := models.WebhookDelivery{Status: models.WebhookDeliveryPending}
+
+	delivery = attempted(params, delivery, 0, errors.New("timeout"), now)
+	assert.Equal(t, models.WebhookDeliveryPending, delivery.Status)
+	assert.Equal(t, now.Add(time.Second), delivery.NextAttemptAt)
+
+	delivery = attempted(params, delivery, 500, errors.New("webhook responded 500"), now)
+	assert.Equal(t, now.Add(time.Second*2), delivery.NextAttemptAt)
+	assert.Equal(t, 500, delivery.LastStatusCode)
+
+	delivery = attempted(params, delivery, 500, errors.New("webhook responded 500"), now)
+	assert.Equal(t, now.Add(time.Second*3), delivery.NextAttemptAt)
+
+	delivery = attempted(params, delivery, 500, errors.New("webhook responded 500"), now)
+	assert.Equal(t, models.WebhookDeliveryFailed, delivery.Status)
+	assert.Equal(t, 4, delivery.Attempts)
+
+	delivered := attempted(params, models.WebhookDelivery{Attempts: 1, LastError: "timeout"}, 200, nil, now)
+	assert.Equal(t, models.WebhookDeliveryDelivered, delivered.Status)
+	assert.Empty(t, delivered.LastError)
+}
+
+func TestMatchSubscriptions(t *testing.T) {
+	tx := types.Tx{ID: "1", Coin: coin.ETHEREUM, From: "A", To: "B", Meta: types.Transfer{}}
+	subscriptions := map[string]uint{"60_A": 1, "60_B": 2}
+
+	outgoing := tx
+	outgoing.Direction = types.DirectionOutgoing
+	assert.Equal(t, []uint{1}, matchSubscriptions(types.TransactionNotification{Result: outgoing}, subscriptions))
+
+	incoming := tx
+	incoming.Direction = types.DirectionIncoming
+	assert.Equal(t, []uint{2}, matchSubscriptions(types.TransactionNotification{Result: incoming}, subscriptions))
+
+	assert.Empty(t, matchSubscriptions(types.TransactionNotification{Result: incoming}, map[string]uint{"60_C": 3}))
+}
+
+func TestMatchSubscriptions_Memo(t *testing.T) {
+	tx := types.Tx{ID: "1", Coin: coin.RIPPLE, From: "A", To: "B", Memo: "12345", Meta: types.Transfer{}}
+	tx.Direction = types.DirectionIncoming
+	subscriptions := map[string]uint{"144_B": 1, "144_B:12345": 2, "144_B:1": 3}
+
+	assert.Equal(t, []uint{1, 2}, matchSubscriptions(types.TransactionNotification{Result: tx}, subscriptions))
+
+	tx.Memo = ""
+	assert.Equal(t, []uint{1}, matchSubscriptions(types.TransactionNotification{Result: tx}, subscriptions))
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, valid := range []string{
+		"https://example.com/hook",
+		"http://hooks.example.com:8080/path?q=1",
+		"https://93.184.216.34/hook",
+	} {
+		assert.Nil(t, ValidateURL(valid, nil), valid)
+	}
+	for _, invalid := range []string{
+		"/hook",
+		"example.com/hook",
+		"ftp://example.com/hook",
+		"https:///hook",
+		"http://localhost/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://10.0.0.5/hook",
+		"http://172.20.1.1/hook",
+		"http://192.168.1.10/hook",
+		"http://100.100.100.200/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:10.0.0.5]/hook",
+	} {
+		assert.NotNil(t, ValidateURL(invalid, nil), invalid)
+	}
+
+	allowed, err := ParseNetworks([]string{"10.1.0.0/16"})
+	assert.Nil(t, err)
+	assert.Nil(t, ValidateURL("http://10.1.2.3/hook", allowed))
+	assert.NotNil(t, ValidateURL("http://10.2.0.1/hook", allowed))
+
+	_, err = ParseNetworks([]string{"10.1.0.0"})
+	assert.NotNil(t, err)
+}
+
+func TestNewClient_Loopback(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	_, err := send(NewClient(time.Second, nil), models.Webhook{URL: receiver.URL}, 1, []byte(`{}`))
+	assert.NotNil(t, err)
+
+	allowed, err := ParseNetworks([]string{"127.0.0.0/8"})
+	assert.Nil(t, err)
+	_, err = send(NewClient(time.Second, allowed), models.Webhook{URL: receiver.URL}, 1, []byte(`{}`))
+	assert.Nil(t, err)
+}
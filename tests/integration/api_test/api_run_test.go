@@ -0,0 +1,22 @@
+// +build integration
+
+package api_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+)
+
+var database *db.Instance
+
+func TestMain(m *testing.M) {
+	database = setup.RunPgContainer()
+	setup.RunMQContainer()
+	code := m.Run()
+	setup.StopMQContainer()
+	setup.StopPgContainer()
+	os.Exit(code)
+}
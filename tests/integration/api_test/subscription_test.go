@@ -0,0 +1,54 @@
+// +build integration
+
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/api"
+	"github.com/trustwallet/blockatlas/internal"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+	"github.com/trustwallet/golibs/types"
+)
+
+const adminToken = "token"
+
+func TestDeleteSubscription_Memo(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+	assert.Nil(t, internal.SubscriptionsUpdates.Declare("fanout"))
+
+	address := "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh"
+	assert.Nil(t, database.CreateSubscriptions([]types.Subscription{
+		{Coin: 144, Address: address},
+		{Coin: 144, Address: address + ":1"},
+	}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api.SetupAdminAPI(router, database, adminToken, nil)
+
+	request := httptest.NewRequest(http.MethodDelete, "/admin/v1/subscriptions/144/"+address+"?memo=1", nil)
+	request.Header.Set("Authorization", "Bearer "+adminToken)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+
+	subscriptions, err := database.GetSubscriptions([]string{"144_" + address})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 1)
+	assert.Equal(t, "", subscriptions[0].Memo)
+
+	request = httptest.NewRequest(http.MethodDelete, "/admin/v1/subscriptions/144/"+address, nil)
+	request.Header.Set("Authorization", "Bearer "+adminToken)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusNoContent, response.Code)
+
+	subscriptions, err = database.GetSubscriptions([]string{"144_" + address})
+	assert.Nil(t, err)
+	assert.Empty(t, subscriptions)
+}
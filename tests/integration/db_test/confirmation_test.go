@@ -0,0 +1,41 @@
+// +build integration
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+)
+
+func TestDb_ConfirmationStates(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	pending := []models.ConfirmationState{
+		{Coin: "bitcoin", Number: 10, Txs: []byte(`[]`)},
+		{Coin: "bitcoin", Number: 11, Txs: []byte(`[]`)},
+		{Coin: "bitcoin", Number: 12, Txs: []byte(`[]`)},
+	}
+	assert.Nil(t, database.SaveParseStep("bitcoin", "", db.ParseStep{Height: 12, Pending: pending}))
+
+	states, err := database.GetConfirmationStates("bitcoin", 11)
+	assert.Nil(t, err)
+	assert.Len(t, states, 2)
+	assert.Equal(t, int64(10), states[0].Number)
+
+	states[1].Confirmations = 1
+	assert.Nil(t, database.SaveParseStep("bitcoin", "", db.ParseStep{Height: 13, Pending: states[1:], Final: []int64{10}}))
+	states, err = database.GetConfirmationStates("bitcoin", 13)
+	assert.Nil(t, err)
+	assert.Len(t, states, 2)
+	assert.Equal(t, int64(11), states[0].Number)
+	assert.Equal(t, int64(1), states[0].Confirmations)
+
+	assert.Nil(t, database.RollbackParsedBlocks("bitcoin", "", 11, nil))
+	states, err = database.GetConfirmationStates("bitcoin", 13)
+	assert.Nil(t, err)
+	assert.Len(t, states, 1)
+}
@@ -0,0 +1,38 @@
+// +build integration
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+)
+
+func TestDb_ClaimNotifications(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	claimed, err := database.ClaimNotifications([]string{"a", "b", "b"}, time.Minute)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, claimed)
+
+	claimed, err = database.ClaimNotifications([]string{"a", "c"}, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c"}, claimed)
+
+	assert.Nil(t, database.ReleaseNotifications([]string{"a"}))
+	claimed, err = database.ClaimNotifications([]string{"a"}, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, claimed)
+
+	time.Sleep(time.Millisecond * 10)
+	claimed, err = database.ClaimNotifications([]string{"a", "b"}, time.Millisecond)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, claimed)
+
+	assert.Nil(t, database.DeleteExpiredNotifications(time.Now().Add(time.Minute)))
+	claimed, err = database.ClaimNotifications([]string{"a"}, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a"}, claimed)
+}
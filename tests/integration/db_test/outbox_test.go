@@ -0,0 +1,48 @@
+// +build integration
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+)
+
+func TestDb_Outbox(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	messages := []models.OutboxMessage{{Coin: "ethereum", Body: []byte(`[]`)}}
+	assert.Nil(t, database.SaveParseStep("ethereum", "", db.ParseStep{Height: 10, Messages: messages}))
+	assert.Nil(t, database.SaveParseStep("ethereum", "", db.ParseStep{Height: 11}))
+
+	tracker, err := database.GetLastParsedBlockNumber("ethereum")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), tracker.Height)
+
+	_, err = database.AddFailedBlocks("ethereum", map[int64]string{5: "timeout"})
+	assert.Nil(t, err)
+	assert.Nil(t, database.ResolveFailedBlock("ethereum", "", 5, []models.OutboxMessage{{Coin: "ethereum", Body: []byte(`[{}]`)}}, nil))
+	failed, err := database.GetFailedBlocks("ethereum")
+	assert.Nil(t, err)
+	assert.Empty(t, failed)
+
+	pending, err := database.GetOutboxMessages("ethereum", 10)
+	assert.Nil(t, err)
+	assert.Len(t, pending, 2)
+	assert.Equal(t, []byte(`[]`), pending[0].Body)
+
+	assert.Nil(t, database.MarkOutboxMessagesDelivered([]uint64{pending[0].ID}))
+	pending, err = database.GetOutboxMessages("ethereum", 10)
+	assert.Nil(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, []byte(`[{}]`), pending[0].Body)
+
+	assert.Nil(t, database.DeleteDeliveredOutboxMessages("ethereum", time.Now().Add(time.Minute)))
+	var count int64
+	assert.Nil(t, database.Gorm.Model(&models.OutboxMessage{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
@@ -0,0 +1,134 @@
+// +build integration
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestDb_SubscriptionRules(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateSubscriptions([]types.Subscription{{Coin: 60, Address: "0xa"}}))
+	subscriptions, err := database.GetSubscriptions([]string{"60_0xa"})
+	assert.Nil(t, err)
+	assert.Equal(t, models.SubscriptionRules{}, subscriptions[0].Rules)
+
+	rules := models.SubscriptionRules{MinAmount: "100", DenyTokens: []string{"0xspam"}}
+	assert.Nil(t, database.SetSubscriptionRules([]string{"60_0xa"}, rules))
+	subscriptions, err = database.GetSubscriptions([]string{"60_0xa"})
+	assert.Nil(t, err)
+	assert.Equal(t, rules, subscriptions[0].Rules)
+}
+
+func TestDb_GetSubscriptionsPage(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateSubscriptions([]types.Subscription{
+		{Coin: 60, Address: "0xa"},
+		{Coin: 60, Address: "0xb"},
+		{Coin: 714, Address: "bnb1"},
+	}))
+
+	page, err := database.GetSubscriptionsPage(0, 2)
+	assert.Nil(t, err)
+	assert.Len(t, page, 2)
+	assert.True(t, page[0].ID < page[1].ID)
+
+	page, err = database.GetSubscriptionsPage(page[1].ID, 2)
+	assert.Nil(t, err)
+	assert.Len(t, page, 1)
+}
+
+func TestDb_ListSubscriptions(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	// one at a time, for the ids to follow the order
+	for _, subscription := range []types.Subscription{
+		{Coin: 60, Address: "0xa"},
+		{Coin: 6, Address: "0xb"},
+		{Coin: 60, Address: "0xc"},
+	} {
+		assert.Nil(t, database.CreateSubscriptions([]types.Subscription{subscription}))
+	}
+	assert.Nil(t, database.SetSubscriptionRules([]string{"60_0xa"}, models.SubscriptionRules{MinAmount: "1"}))
+
+	all, err := database.ListSubscriptions("", 0, 10)
+	assert.Nil(t, err)
+	assert.Len(t, all, 3)
+
+	page, err := database.ListSubscriptions("60", 0, 1)
+	assert.Nil(t, err)
+	assert.Len(t, page, 1)
+	assert.Equal(t, "60_0xa", page[0].Address)
+	assert.Equal(t, "1", page[0].Rules.MinAmount)
+
+	page, err = database.ListSubscriptions("60", page[0].ID, 10)
+	assert.Nil(t, err)
+	assert.Len(t, page, 1)
+	assert.Equal(t, "60_0xc", page[0].Address)
+}
+
+func TestDb_CanonicalizeSubscriptions(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.Gorm.Create(&[]models.Subscription{
+		{Address: "60_0xAbC0000000000000000000000000000000000000"},
+		{Address: "145_bitcoincash:qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a"},
+		{Address: "145_1BpEi6DfDAUFd7GtittLSdBeYJvcoaVggu"},
+		{Address: "714_bnb1abc"},
+	}).Error)
+
+	rewritten, err := database.CanonicalizeSubscriptions()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, rewritten)
+
+	subscriptions, err := database.GetSubscriptionsPage(0, 10)
+	assert.Nil(t, err)
+	addresses := make([]string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		addresses = append(addresses, subscription.Address)
+	}
+	assert.ElementsMatch(t, []string{
+		"60_0xabc0000000000000000000000000000000000000",
+		"145_qpm2qsznhks23z7629mms6s4cwef74vcwvy22gdx6a",
+		"714_bnb1abc",
+	}, addresses)
+
+	assert.Nil(t, database.CreateSubscriptions([]types.Subscription{{Coin: 60, Address: "0xABC0000000000000000000000000000000000000"}}))
+	subscriptions, err = database.GetSubscriptions([]string{"60_0xabc0000000000000000000000000000000000000"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 1)
+}
+
+func TestDb_MemoSubscriptions(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateSubscriptions([]types.Subscription{
+		{Coin: 144, Address: "r"},
+		{Coin: 144, Address: "r:1"},
+		{Coin: 144, Address: "r:2"},
+	}))
+
+	subscriptions, err := database.GetSubscriptions([]string{"144_r"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 3)
+
+	subscriptions, err = database.GetSubscriptionsByKeys([]string{"144_r:1"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 1)
+	assert.Equal(t, "144_r", subscriptions[0].Address)
+	assert.Equal(t, "1", subscriptions[0].Memo)
+	assert.Equal(t, "144_r:1", subscriptions[0].Key())
+
+	assert.Nil(t, database.DeleteSubscriptions([]string{"144_r:1", "144_r"}))
+	subscriptions, err = database.GetSubscriptions([]string{"144_r"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 1)
+	assert.Equal(t, "2", subscriptions[0].Memo)
+}
@@ -4,8 +4,11 @@ package db_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db"
+	"github.com/trustwallet/blockatlas/db/models"
 	"github.com/trustwallet/blockatlas/tests/integration/setup"
 )
 
@@ -24,3 +27,162 @@ func TestDb_SetBlock(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, newBlock.Height, int64(110))
 }
+
+func TestDb_RollbackParsedBlocks(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.SetLastParsedBlockNumber("ethereum", 12))
+	assert.Nil(t, database.SaveParsedBlocks("ethereum", []models.ParsedBlock{
+		{Coin: "ethereum", Number: 10, Hash: "a10", ParentHash: "a9"},
+		{Coin: "ethereum", Number: 11, Hash: "a11", ParentHash: "a10"},
+		{Coin: "ethereum", Number: 12, Hash: "a12", ParentHash: "a11"},
+	}, 11))
+
+	blocks, err := database.GetParsedBlocks("ethereum", 0, 13)
+	assert.Nil(t, err)
+	assert.Len(t, blocks, 2)
+	assert.Equal(t, int64(12), blocks[0].Number)
+
+	assert.Nil(t, database.RollbackParsedBlocks("ethereum", "", 11, nil))
+
+	blocks, err = database.GetParsedBlocks("ethereum", 0, 13)
+	assert.Nil(t, err)
+	assert.Len(t, blocks, 1)
+
+	tracker, err := database.GetLastParsedBlockNumber("ethereum")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(11), tracker.Height)
+}
+
+func TestDb_FailedBlocks(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	blocks, err := database.AddFailedBlocks("ethereum", map[int64]string{10: "timeout", 11: "timeout"})
+	assert.Nil(t, err)
+	assert.Len(t, blocks, 2)
+
+	blocks, err = database.AddFailedBlocks("ethereum", map[int64]string{11: "not found"})
+	assert.Nil(t, err)
+	assert.Len(t, blocks, 1)
+	assert.Equal(t, 2, blocks[0].Attempts)
+	assert.Equal(t, "not found", blocks[0].LastError)
+
+	toRetry, err := database.GetFailedBlocksToRetry("ethereum", 2, time.Now().Add(time.Minute), 10)
+	assert.Nil(t, err)
+	assert.Len(t, toRetry, 1)
+	assert.Equal(t, int64(11), toRetry[0].Number)
+
+	parked, err := database.FailRetriedBlock("ethereum", 11, "timeout", 2)
+	assert.Nil(t, err)
+	assert.False(t, parked)
+	parked, err = database.FailRetriedBlock("ethereum", 11, "timeout", 2)
+	assert.Nil(t, err)
+	assert.True(t, parked)
+
+	toRetry, err = database.GetFailedBlocksToRetry("ethereum", 2, time.Now().Add(time.Minute), 10)
+	assert.Nil(t, err)
+	assert.Empty(t, toRetry)
+
+	assert.Nil(t, database.UnparkFailedBlock("ethereum", 11))
+	assert.NotNil(t, database.UnparkFailedBlock("ethereum", 11))
+	toRetry, err = database.GetFailedBlocksToRetry("ethereum", 2, time.Now().Add(time.Minute), 10)
+	assert.Nil(t, err)
+	assert.Len(t, toRetry, 1)
+	assert.Equal(t, 0, toRetry[0].Retries)
+
+	assert.Nil(t, database.SkipFailedBlock("ethereum", 11))
+	assert.NotNil(t, database.SkipFailedBlock("ethereum", 12))
+
+	toRetry, err = database.GetFailedBlocksToRetry("ethereum", 2, time.Now().Add(time.Minute), 10)
+	assert.Nil(t, err)
+	assert.Empty(t, toRetry)
+
+	assert.Nil(t, database.DeleteFailedBlocks("ethereum", []int64{10, 11}))
+	all, err := database.GetFailedBlocks("ethereum")
+	assert.Nil(t, err)
+	assert.Empty(t, all)
+}
+
+func TestDb_Backfill(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	_, err := database.GetBackfill("ethereum_1_100")
+	assert.NotNil(t, err)
+
+	backfill := models.Backfill{Name: "ethereum_1_100", Coin: "ethereum", From: 1, To: 100, Height: 10}
+	assert.Nil(t, database.SaveBackfill(backfill))
+	backfill.Height = 20
+	assert.Nil(t, database.SaveBackfill(backfill))
+
+	saved, err := database.GetBackfill("ethereum_1_100")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), saved.Height)
+	assert.Equal(t, int64(1), saved.From)
+}
+
+func TestDb_Lease(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	acquired, err := database.AcquireLease("ethereum", "a", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+
+	acquired, err = database.AcquireLease("ethereum", "b", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+
+	acquired, err = database.AcquireLease("ethereum", "a", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+
+	leases, err := database.GetLeases()
+	assert.Nil(t, err)
+	assert.Len(t, leases, 1)
+	assert.Equal(t, "a", leases[0].Holder)
+
+	assert.Nil(t, database.ReleaseLease("ethereum", "b"))
+	acquired, err = database.AcquireLease("ethereum", "b", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+
+	assert.Nil(t, database.ReleaseLease("ethereum", "a"))
+	acquired, err = database.AcquireLease("ethereum", "b", time.Millisecond)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+
+	time.Sleep(time.Millisecond * 10)
+	acquired, err = database.AcquireLease("ethereum", "a", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+}
+
+func TestDb_LeaseFencing(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	acquired, err := database.AcquireLease("ethereum", "a", time.Millisecond)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	time.Sleep(time.Millisecond * 10)
+	acquired, err = database.AcquireLease("ethereum", "b", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+
+	messages := []models.OutboxMessage{{Coin: "ethereum", Body: []byte(`[]`)}}
+	blocks := []models.ParsedBlock{{Coin: "ethereum", Number: 10, Hash: "a10", ParentHash: "a9"}}
+	assert.Equal(t, db.ErrLeaseLost, database.SaveParseStep("ethereum", "a", db.ParseStep{Height: 10, Blocks: blocks, Messages: messages}))
+	assert.Equal(t, db.ErrLeaseLost, database.RollbackParsedBlocks("ethereum", "a", 5, messages))
+	pending, err := database.GetOutboxMessages("ethereum", 10)
+	assert.Nil(t, err)
+	assert.Empty(t, pending)
+	parsed, err := database.GetParsedBlocks("ethereum", 0, 11)
+	assert.Nil(t, err)
+	assert.Empty(t, parsed)
+
+	assert.Nil(t, database.SaveParseStep("ethereum", "b", db.ParseStep{Height: 10, Blocks: blocks, Messages: messages}))
+	tracker, err := database.GetLastParsedBlockNumber("ethereum")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), tracker.Height)
+	parsed, err = database.GetParsedBlocks("ethereum", 0, 11)
+	assert.Nil(t, err)
+	assert.Len(t, parsed, 1)
+}
@@ -0,0 +1,88 @@
+// +build integration
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestDb_WalletSubscriptions(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateSubscriptions([]types.Subscription{{Coin: 60, Address: "0xa"}, {Coin: 60, Address: "0xb"}}))
+	assert.Nil(t, database.SubscribeWallet("w1", []string{"60_0xa", "60_0xb"}))
+	assert.Nil(t, database.SubscribeWallet("w2", []string{"60_0xa"}))
+	assert.Nil(t, database.SubscribeWallet("w2", []string{"60_0xa"}))
+
+	subscriptions, err := database.GetSubscriptions([]string{"60_0xa", "60_0xb"})
+	assert.Nil(t, err)
+	ids := make(map[string]uint)
+	for _, subscription := range subscriptions {
+		ids[subscription.Address] = subscription.ID
+	}
+	wallets, err := database.GetSubscriptionWallets([]uint{ids["60_0xa"], ids["60_0xb"]})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"w1", "w2"}, wallets[ids["60_0xa"]])
+	assert.Equal(t, []string{"w1"}, wallets[ids["60_0xb"]])
+
+	deleted, err := database.UnsubscribeWallet("w1", []string{"60_0xa", "60_0xb"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"60_0xb"}, deleted)
+	subscriptions, err = database.GetSubscriptions([]string{"60_0xa", "60_0xb"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 1)
+
+	deleted, err = database.UnsubscribeWallet("w2", []string{"60_0xa"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"60_0xa"}, deleted)
+	subscriptions, err = database.GetSubscriptions([]string{"60_0xa"})
+	assert.Nil(t, err)
+	assert.Empty(t, subscriptions)
+}
+
+func TestDb_XpubWallets(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateXpubAddresses(0, "zpub1", []models.XpubAddress{{Address: "0_bc1a"}, {Address: "0_bc1b", Index: 1}}))
+	assert.Nil(t, database.SubscribeWallet("w1", []string{"0_bc1a", "0_bc1b"}))
+	assert.Nil(t, database.SubscribeWallet("w2", []string{"0_bc1a"}))
+
+	xpubAddresses, err := database.GetXpubAddresses([]string{"0_bc1a"})
+	assert.Nil(t, err)
+	wallets, err := database.GetXpubWallets([]uint{xpubAddresses[0].XpubID})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"w1", "w2"}, wallets[xpubAddresses[0].XpubID])
+
+	// derived addresses are left to their xpub
+	deleted, err := database.UnsubscribeWallet("w2", []string{"0_bc1a"})
+	assert.Nil(t, err)
+	assert.Empty(t, deleted)
+
+	referenced, err := database.UnsubscribeXpubWallet(0, "zpub1", "w1")
+	assert.Nil(t, err)
+	assert.False(t, referenced)
+}
+
+func TestDb_CreateWalletSubscriptions(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateWalletSubscriptions("w1", []types.Subscription{{Coin: 60, Address: "0xa"}}))
+	assert.Nil(t, database.CreateWalletSubscriptions("", []types.Subscription{{Coin: 60, Address: "0xb"}}))
+	subscriptions, err := database.GetSubscriptions([]string{"60_0xa", "60_0xb"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 2)
+
+	// a delete without wallet leaves the subscriptions another wallet references
+	deleted, err := database.DeleteUnreferencedSubscriptions([]string{"60_0xa", "60_0xb"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"60_0xb"}, deleted)
+	subscriptions, err = database.GetSubscriptions([]string{"60_0xa", "60_0xb"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 1)
+	assert.Equal(t, "60_0xa", subscriptions[0].Address)
+}
@@ -0,0 +1,71 @@
+// +build integration
+
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+	"github.com/trustwallet/golibs/types"
+)
+
+func TestDb_Webhooks(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateSubscriptions([]types.Subscription{{Coin: 60, Address: "0xa"}}))
+	subscriptions, err := database.GetSubscriptions([]string{"60_0xa"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 1)
+
+	webhook := models.Webhook{SubscriptionID: subscriptions[0].ID, URL: "http://example.com/hook", Secret: "secret"}
+	assert.Nil(t, database.CreateWebhook(&webhook))
+	webhooks, err := database.GetWebhooksBySubscriptionIDs([]uint{subscriptions[0].ID})
+	assert.Nil(t, err)
+	assert.Len(t, webhooks, 1)
+
+	deliveries := []models.WebhookDelivery{{
+		WebhookID:     webhook.ID,
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: time.Now().Add(-time.Second),
+		Payload:       []byte(`{}`),
+	}}
+	assert.Nil(t, database.CreateWebhookDeliveries(deliveries))
+	assert.NotZero(t, deliveries[0].ID)
+
+	due, err := database.ClaimDueWebhookDeliveries(time.Now(), time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, "http://example.com/hook", due[0].Webhook.URL)
+
+	claimed, err := database.ClaimDueWebhookDeliveries(time.Now(), time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, claimed)
+
+	due[0].Attempts = 3
+	due[0].LastError = "webhook responded 500"
+	assert.Nil(t, database.ParkWebhookDelivery(due[0]))
+
+	due, err = database.ClaimDueWebhookDeliveries(time.Now().Add(time.Hour), time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Empty(t, due)
+	deadLetters, err := database.GetWebhookDeadLetters(10, 0)
+	assert.Nil(t, err)
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, 3, deadLetters[0].Attempts)
+
+	replayed, err := database.ReplayWebhookDeadLetters([]uint64{deliveries[0].ID})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), replayed)
+	due, err = database.ClaimDueWebhookDeliveries(time.Now().Add(time.Second), time.Minute, 10)
+	assert.Nil(t, err)
+	assert.Len(t, due, 1)
+	assert.Equal(t, 0, due[0].Attempts)
+
+	assert.Nil(t, database.DeleteSubscriptions([]string{"60_0xa"}))
+	webhooks, err = database.GetWebhooksBySubscriptionIDs([]uint{subscriptions[0].ID})
+	assert.Nil(t, err)
+	assert.Empty(t, webhooks)
+}
@@ -0,0 +1,53 @@
+// +build integration
+
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/trustwallet/blockatlas/db/models"
+	"github.com/trustwallet/blockatlas/tests/integration/setup"
+)
+
+func TestDb_Xpubs(t *testing.T) {
+	setup.CleanupPgContainer(database.Gorm)
+
+	assert.Nil(t, database.CreateXpubAddresses(0, "zpub1", []models.XpubAddress{
+		{Address: "0_bc1a", Index: 0, Used: true},
+		{Address: "0_bc1b", Index: 1},
+	}))
+	subscriptions, err := database.GetSubscriptions([]string{"0_bc1a", "0_bc1b"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 2)
+
+	xpubs, err := database.MarkXpubAddressesUsed([]string{"0_bc1a"})
+	assert.Nil(t, err)
+	assert.Empty(t, xpubs)
+	xpubs, err = database.MarkXpubAddressesUsed([]string{"0_bc1a", "0_bc1b"})
+	assert.Nil(t, err)
+	assert.Len(t, xpubs, 1)
+	assert.Equal(t, "zpub1", xpubs[0].Key)
+
+	assert.Nil(t, database.CreateXpubAddresses(0, "zpub1", []models.XpubAddress{
+		{Address: "0_bc1b", Index: 1},
+		{Address: "0_bc1c", Index: 2},
+	}))
+	xpubAddresses, err := database.GetXpubAddresses([]string{"0_bc1a", "0_bc1b", "0_bc1c", "0_other"})
+	assert.Nil(t, err)
+	assert.Len(t, xpubAddresses, 3)
+	for _, address := range xpubAddresses {
+		assert.Equal(t, "zpub1", address.Xpub.Key)
+		assert.Equal(t, address.Address != "0_bc1c", address.Used)
+	}
+
+	assert.Nil(t, database.CreateXpubAddresses(0, "zpub2", []models.XpubAddress{{Address: "0_bc1c", Index: 0}}))
+	assert.Nil(t, database.SubscribeWallet("w1", []string{"0_bc1a"}))
+	assert.Nil(t, database.DeleteXpub(0, "zpub1"))
+	subscriptions, err = database.GetSubscriptions([]string{"0_bc1a", "0_bc1b", "0_bc1c"})
+	assert.Nil(t, err)
+	assert.Len(t, subscriptions, 2)
+	for _, subscription := range subscriptions {
+		assert.NotEqual(t, "0_bc1b", subscription.Address)
+	}
+}
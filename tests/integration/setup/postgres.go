@@ -26,9 +26,23 @@ var (
 
 	tables = []interface{}{
 		&models.Tracker{},
+		&models.ParsedBlock{},
+		&models.ConfirmationState{},
+		&models.FailedBlock{},
+		&models.Backfill{},
+		&models.Lease{},
+		&models.OutboxMessage{},
 		&models.Asset{},
 		&models.Subscription{},
 		&models.SubscriptionsAssetAssociation{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.WebhookDeadLetter{},
+		&models.NotificationDedup{},
+		&models.Xpub{},
+		&models.XpubAddress{},
+		&models.Wallet{},
+		&models.WalletSubscription{},
 	}
 
 	url string